@@ -14,6 +14,8 @@ import (
 	parseutil "gopkg.in/src-d/go-parse-utils.v1"
 )
 
+// Schema is the prototype of the parser output, the contract now lives in
+// sudarshana-parser/schema
 type Schema struct {
 	Source  string `json:"source"`
 	Line    int64  `json:"line"`
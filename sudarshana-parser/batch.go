@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"text/tabwriter"
+)
+
+// PackageSummary is reported for every package at the end of a batch run
+type PackageSummary struct {
+	Package     string
+	Files       int
+	Expressions int
+	Errors      []string
+}
+
+type batchResult struct {
+	sources []SourceFile
+	summary PackageSummary
+}
+
+// readPackageList reads one package per line, ignoring empty lines and # comments.
+// "-" reads the list from stdin.
+func readPackageList(listFile string) ([]string, error) {
+	var reader io.Reader
+	if listFile == "-" {
+		reader = os.Stdin
+	} else {
+		file, err := os.Open(listFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	packages := make([]string, 0)
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		packages = append(packages, line)
+	}
+	return packages, scanner.Err()
+}
+
+// batch parses all the packages in the list on a pool of workers. The output is
+// written in the order of the list (and files in the order of their names) irrespective
+// of which worker finishes first, so two runs over the same input are identical.
+func batch(root string, listFile string, workers int, options ParseOptions, sink Sink) {
+	packages, err := readPackageList(listFile)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	results := make([]chan batchResult, len(packages))
+	for idx := range results {
+		results[idx] = make(chan batchResult, 1)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				results[idx] <- parseBatchPackage(root, packages[idx], options)
+			}
+		}()
+	}
+	go func() {
+		for idx := range packages {
+			jobs <- idx
+		}
+		close(jobs)
+	}()
+
+	summaries := make([]PackageSummary, 0, len(packages))
+	for idx := range packages {
+		result := <-results[idx]
+		for _, source := range result.sources {
+			writeSource(sink, source)
+		}
+		summaries = append(summaries, result.summary)
+	}
+	wg.Wait()
+
+	printSummary(os.Stderr, summaries)
+}
+
+func parseBatchPackage(root string, inputPackage string, options ParseOptions) batchResult {
+	summary := PackageSummary{Package: inputPackage}
+	sources, err := parseInputPackage(root, inputPackage, options)
+	if err != nil {
+		summary.Errors = append(summary.Errors, err.Error())
+	}
+	summary.Files = len(sources)
+	for _, source := range sources {
+		summary.Expressions += len(source.Exprs)
+		for _, parseError := range source.Errors {
+			summary.Errors = append(summary.Errors, parseError.Error())
+		}
+	}
+	return batchResult{sources: sources, summary: summary}
+}
+
+// printSummary writes a table of files, expressions and errors per package
+func printSummary(out io.Writer, summaries []PackageSummary) {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(writer, "package\tfiles\texpressions\terrors\n")
+	var files, expressions, errors int
+	for _, summary := range summaries {
+		fmt.Fprintf(writer, "%s\t%d\t%d\t%d\n", summary.Package, summary.Files, summary.Expressions, len(summary.Errors))
+		files += summary.Files
+		expressions += summary.Expressions
+		errors += len(summary.Errors)
+	}
+	fmt.Fprintf(writer, "total\t%d\t%d\t%d\n", files, expressions, errors)
+	writer.Flush()
+
+	for _, summary := range summaries {
+		for _, err := range summary.Errors {
+			fmt.Fprintf(out, "%s: %s\n", summary.Package, err)
+		}
+	}
+}
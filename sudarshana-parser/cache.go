@@ -0,0 +1,248 @@
+package main
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+
+	"github.com/ashwanthkumar/devmerge_2k18/sudarshana-parser/schema"
+)
+
+var versionDirRegex = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+$`)
+
+// Cache keeps the parsed files of a package on disk, keyed by the content of its files and the
+// schema version. The types of a file depend on the rest of its package, so a change to any
+// of its files parses the whole package again. The entries of every schema version are kept
+// in a directory of their own. The types also depend on the versions of the imported packages,
+// so the files that pick them for the root and for the module of the package are part of the key.
+type Cache struct {
+	directory    string
+	dependencies string
+	hits         int64
+	misses       int64
+}
+
+// buildListFiles pick the versions of the dependencies of a module
+var buildListFiles = []string{"go.mod", "go.sum", "go.work", "go.work.sum", filepath.Join("vendor", "modules.txt")}
+
+// NewCache returns the cache in the directory, creating it if needed. root is the directory
+// the packages are resolved from.
+func NewCache(directory string, root string) (*Cache, error) {
+	cache := &Cache{directory: filepath.Join(directory, schema.Version), dependencies: dependencyHash(root)}
+	if err := os.MkdirAll(cache.directory, 0755); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// key hashes everything the output of parsePackage depends on, except the Meta which is
+// filled in again on every run. It's false when a file can't be read, those aren't cached.
+func (c *Cache) key(packagePath string, packageName string, directory string, module *Module, filenames []string, options ParseOptions) (string, bool) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%s\x00", schema.Version, packagePath, packageName, directory)
+	if nil != module {
+		fmt.Fprintf(hash, "%s@%s=%s\x00", module.Path, module.Version, module.Replace)
+	}
+	fmt.Fprintf(hash, "%v\x00%v\x00", options.Targets, options.SkipCgo)
+	fmt.Fprintf(hash, "%s\x00%s\x00", c.dependencies, dependencyHash(directory))
+	for _, filename := range filenames {
+		content, err := ioutil.ReadFile(filepath.Join(directory, filename))
+		if err != nil {
+			return "", false
+		}
+		sum := sha256.Sum256(content)
+		fmt.Fprintf(hash, "%s\x00%x\x00", filename, sum)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// dependencyHash hashes the build list files found from the directory up, the missing ones included
+func dependencyHash(directory string) string {
+	if abs, err := filepath.Abs(directory); err == nil {
+		directory = abs
+	}
+	hash := sha256.New()
+	for _, name := range buildListFiles {
+		sum := [sha256.Size]byte{}
+		if path, ok := findUp(directory, "", name); ok {
+			if content, err := ioutil.ReadFile(path); err == nil {
+				sum = sha256.Sum256(content)
+			}
+		}
+		fmt.Fprintf(hash, "%s\x00%x\x00", name, sum)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.directory, key[:2], key+".ndjson.gz")
+}
+
+// load replays the files of the entry, a broken entry is a miss
+func (c *Cache) load(key string) ([]SourceFile, bool) {
+	path := c.path(key)
+	file, err := os.Open(path)
+	if err != nil {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		atomic.AddInt64(&c.misses, 1)
+		return nil, false
+	}
+
+	sources := make([]SourceFile, 0)
+	decoder := schema.NewDecoder(reader)
+	for {
+		source, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			atomic.AddInt64(&c.misses, 1)
+			return nil, false
+		}
+		sources = append(sources, *source)
+	}
+	atomic.AddInt64(&c.hits, 1)
+	// prune goes by the time an entry was last used
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return sources, true
+}
+
+// store writes the entry to a temporary file first, so concurrent runs never see half an entry
+func (c *Cache) store(key string, sources []SourceFile) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	writer := gzip.NewWriter(tmp)
+	encoder := json.NewEncoder(writer)
+	for _, source := range sources {
+		if err := encoder.Encode(source); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// printStats writes the hits and misses of the run
+func (c *Cache) printStats(out io.Writer) {
+	if nil == c {
+		return
+	}
+	hits, misses := atomic.LoadInt64(&c.hits), atomic.LoadInt64(&c.misses)
+	ratio := 0.0
+	if hits+misses > 0 {
+		ratio = 100 * float64(hits) / float64(hits+misses)
+	}
+	fmt.Fprintf(out, "cache: %d packages replayed, %d parsed (%.1f%% hit rate) in %s\n", hits, misses, ratio, c.directory)
+}
+
+// cachedParsePackage replays the package from the cache when none of its files changed, and
+// parses (and caches) it otherwise
+func cachedParsePackage(packagePath string, packageName string, directory string, module *Module, meta Meta, filenames []string, options ParseOptions) []SourceFile {
+	cache := options.Cache
+	if nil == cache {
+		return parsePackage(packagePath, packageName, directory, module, meta, filenames, options)
+	}
+	key, ok := cache.key(packagePath, packageName, directory, module, filenames, options)
+	if !ok {
+		return parsePackage(packagePath, packageName, directory, module, meta, filenames, options)
+	}
+	if sources, hit := cache.load(key); hit {
+		for idx := range sources {
+			sources[idx].Meta = meta
+		}
+		return sources
+	}
+	sources := parsePackage(packagePath, packageName, directory, module, meta, filenames, options)
+	if err := cache.store(key, sources); err != nil {
+		log.Printf("failed to cache %s: %v", directory, err)
+	}
+	return sources
+}
+
+// pruneCache removes the entries of other schema versions and the ones not used for maxAge
+func pruneCache(directory string, maxAge time.Duration) {
+	versions, err := ioutil.ReadDir(directory)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	removed, kept := 0, 0
+	var freed int64
+	cutoff := time.Now().Add(-maxAge)
+	for _, version := range versions {
+		versionDir := filepath.Join(directory, version.Name())
+		// anything that isn't a version directory isn't ours to remove
+		if !version.IsDir() || !versionDirRegex.MatchString(version.Name()) {
+			continue
+		}
+		if version.Name() != schema.Version {
+			size, entries := directorySize(versionDir)
+			if err := os.RemoveAll(versionDir); err != nil {
+				log.Printf("failed to remove %s: %v", versionDir, err)
+				continue
+			}
+			freed += size
+			removed += entries
+			continue
+		}
+		filepath.Walk(versionDir, func(path string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return nil
+			}
+			if maxAge > 0 && info.ModTime().Before(cutoff) {
+				if os.Remove(path) == nil {
+					freed += info.Size()
+					removed++
+				}
+				return nil
+			}
+			kept++
+			return nil
+		})
+	}
+	fmt.Fprintf(os.Stderr, "cache: removed %d entries (%d bytes), kept %d\n", removed, freed, kept)
+}
+
+// directorySize returns the bytes and the number of files in the directory
+func directorySize(directory string) (int64, int) {
+	var size int64
+	files := 0
+	filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			size += info.Size()
+			files++
+		}
+		return nil
+	})
+	return size, files
+}
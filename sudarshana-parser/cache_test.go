@@ -0,0 +1,34 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheKeyFollowsDependencies(t *testing.T) {
+	directory := writeFiles(t, map[string]string{
+		"go.mod": "module example.com/dep\n\nrequire example.com/lib v1.0.0\n",
+		"go.sum": "example.com/lib v1.0.0 h1:a=\n",
+		"a.go":   "package dep\n",
+	})
+	cache, err := NewCache(t.TempDir(), directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := func() string {
+		key, ok := cache.key("example.com/dep", "dep", directory, nil, []string{"a.go"}, ParseOptions{})
+		if !ok {
+			t.Fatal("expected a key")
+		}
+		return key
+	}
+
+	before := key()
+	if err := ioutil.WriteFile(filepath.Join(directory, "go.sum"), []byte("example.com/lib v1.1.0 h1:b=\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if key() == before {
+		t.Errorf("expected a new key when go.sum changes")
+	}
+}
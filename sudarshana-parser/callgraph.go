@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CallGraph has an edge from every function to each of the functions it calls
+type CallGraph struct {
+	Packages []string        `json:"packages"`
+	Nodes    []CallGraphNode `json:"nodes"`
+	Edges    []CallEdge      `json:"edges"`
+}
+
+// CallGraphNode is a function, named like the Callee of a Func without the pointer of the
+// receiver - github.com/gin-gonic/gin.Context#JSON or github.com/gin-gonic/gin#New
+type CallGraphNode struct {
+	ID      string `json:"id"`
+	Package string `json:"package"`
+	// External is set for functions that aren't declared in any of the parsed packages
+	External bool `json:"external,omitempty"`
+}
+
+// CallEdge is a caller calling the callee, at one or more call sites
+type CallEdge struct {
+	Caller string         `json:"caller"`
+	Callee string         `json:"callee"`
+	Sites  []CallLocation `json:"sites"`
+}
+
+// CallLocation is where the callee is called from
+type CallLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Offset int    `json:"offset"`
+	// Closure is set when the call is made from a function literal inside the caller
+	Closure string `json:"closure,omitempty"`
+	Context string `json:"context,omitempty"`
+}
+
+// callgraphMode prints the call graph of a package, or of all the packages in a list file
+// ("-" reads the list from stdin), as json or dot
+func callgraphMode(root string, target string, format string, out string, options ParseOptions) {
+	packages := []string{target}
+	if info, err := os.Stat(target); target == "-" || (err == nil && !info.IsDir()) {
+		list, err := readPackageList(target)
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+		packages = list
+	}
+
+	builder := newCallGraphBuilder()
+	for _, inputPackage := range packages {
+		sources, err := parseInputPackage(root, inputPackage, options)
+		if err != nil {
+			log.Printf("failed to parse %s: %v", inputPackage, err)
+			continue
+		}
+		builder.declare(packagePathOf(sources, inputPackage), sources)
+		for _, source := range sources {
+			builder.add(source)
+		}
+	}
+	graph := builder.build(packages)
+
+	writer := io.Writer(os.Stdout)
+	if out != "" && out != "-" {
+		file, err := os.Create(out)
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+		defer file.Close()
+		writer = file
+	}
+	switch format {
+	case "json", "ndjson":
+		outAsJSON, err := json.Marshal(graph)
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+		fmt.Fprintf(writer, "%s\n", string(outAsJSON))
+	case "dot":
+		writeDot(writer, graph)
+	default:
+		log.Fatalf("unknown callgraph format %q, expected json or dot", format)
+	}
+}
+
+type callGraphBuilder struct {
+	edges    map[[2]string]*CallEdge
+	order    [][2]string
+	declared map[string]bool
+}
+
+func newCallGraphBuilder() *callGraphBuilder {
+	return &callGraphBuilder{
+		edges:    make(map[[2]string]*CallEdge),
+		declared: make(map[string]bool),
+	}
+}
+
+// add records the calls of the file whose callee could be resolved
+func (b *callGraphBuilder) add(source SourceFile) {
+	walkCalls(source.Exprs, func(call Func) {
+		caller := callerOf(call.CScope)
+		callee := strings.TrimPrefix(call.Callee, "*")
+		if callee == "" {
+			return
+		}
+		key := [2]string{caller, callee}
+		edge, present := b.edges[key]
+		if !present {
+			edge = &CallEdge{Caller: caller, Callee: callee}
+			b.edges[key] = edge
+			b.order = append(b.order, key)
+		}
+		edge.Sites = append(edge.Sites, CallLocation{
+			File:    call.File,
+			Line:    call.Line,
+			Column:  call.Column,
+			Offset:  call.ByteOffset,
+			Closure: call.CScope.Closure,
+			Context: call.Context,
+		})
+	})
+}
+
+// declare records the functions declared in the files of the package, the ones that make no
+// resolved calls of their own included
+func (b *callGraphBuilder) declare(packagePath string, sources []SourceFile) {
+	for _, source := range sources {
+		fset := token.NewFileSet()
+		fileAst, _ := parser.ParseFile(fset, source.Path, nil, parser.SkipObjectResolution)
+		if nil == fileAst {
+			continue
+		}
+		filePackagePath := packagePath
+		if isExternalTestSource(source) {
+			filePackagePath = packagePath + "_test"
+		}
+		for _, decl := range fileAst.Decls {
+			funcDecl, ok := decl.(*ast.FuncDecl)
+			if !ok {
+				continue
+			}
+			receiver, err := getReceiverType(fset, funcDecl)
+			if err != nil {
+				continue
+			}
+			b.declared[callerOf(Scope{Package: filePackagePath, Function: funcDecl.Name.String(), Receiver: receiver})] = true
+		}
+	}
+}
+
+// packagePathOf returns the import path the files were parsed with, which the scopes of their
+// expressions carry, or the package asked for without its version when they have none
+func packagePathOf(sources []SourceFile, inputPackage string) string {
+	for _, source := range sources {
+		if isExternalTestSource(source) {
+			continue
+		}
+		for _, expr := range source.Exprs {
+			if nil != expr && expr.Scope().Package != "" {
+				return expr.Scope().Package
+			}
+		}
+	}
+	return strings.SplitN(inputPackage, "@", 2)[0]
+}
+
+// isExternalTestSource tells the files of an external test package, their scopes are in foo_test
+func isExternalTestSource(source SourceFile) bool {
+	return source.Test && strings.HasSuffix(source.Package, "_test")
+}
+
+func (b *callGraphBuilder) build(packages []string) CallGraph {
+	graph := CallGraph{Packages: packages, Nodes: make([]CallGraphNode, 0), Edges: make([]CallEdge, 0, len(b.order))}
+	nodes := make(map[string]bool)
+	addNode := func(id string) {
+		if nodes[id] {
+			return
+		}
+		nodes[id] = true
+		graph.Nodes = append(graph.Nodes, CallGraphNode{ID: id, Package: packageOfFunction(id), External: !b.declared[id]})
+	}
+	for _, key := range b.order {
+		edge := b.edges[key]
+		addNode(edge.Caller)
+		addNode(edge.Callee)
+		graph.Edges = append(graph.Edges, *edge)
+	}
+	sort.SliceStable(graph.Nodes, func(i, j int) bool {
+		return graph.Nodes[i].ID < graph.Nodes[j].ID
+	})
+	return graph
+}
+
+// callerOf names the function of the scope like the callees, calls from function literals
+// are made by the function they're in and calls outside of functions by init
+func callerOf(scope Scope) string {
+	function := scope.Function
+	if function == "" {
+		function = "init"
+	}
+	if scope.Receiver != "" {
+		return scope.Package + "." + receiverBaseName(scope.Receiver) + "#" + function
+	}
+	return scope.Package + "#" + function
+}
+
+// packageOfFunction returns the import path of the function, the part before the type or the #
+func packageOfFunction(id string) string {
+	pkg := id
+	if idx := strings.Index(pkg, "#"); idx >= 0 {
+		pkg = pkg[:idx]
+	}
+	slash := strings.LastIndex(pkg, "/")
+	if dot := strings.LastIndex(pkg, "."); dot > slash && isTypeName(pkg[dot+1:]) {
+		pkg = pkg[:dot]
+	}
+	return pkg
+}
+
+// isTypeName tells the type in github.com/gin-gonic/gin.Context from the .v2 in gopkg.in/yaml.v2
+func isTypeName(name string) bool {
+	return name != "" && !(name[0] == 'v' && len(name) > 1 && name[1] >= '0' && name[1] <= '9')
+}
+
+// writeDot writes the graph in Graphviz format, with a cluster per package. External functions
+// are drawn dashed and the edges are labelled with the number of call sites.
+func writeDot(out io.Writer, graph CallGraph) {
+	fmt.Fprintln(out, "digraph callgraph {")
+	fmt.Fprintln(out, "\trankdir=LR;")
+	fmt.Fprintln(out, "\tnode [shape=box, fontsize=10];")
+
+	byPackage := make(map[string][]CallGraphNode)
+	packages := make([]string, 0)
+	for _, node := range graph.Nodes {
+		if _, present := byPackage[node.Package]; !present {
+			packages = append(packages, node.Package)
+		}
+		byPackage[node.Package] = append(byPackage[node.Package], node)
+	}
+	sort.Strings(packages)
+	for idx, pkg := range packages {
+		fmt.Fprintf(out, "\tsubgraph cluster_%d {\n", idx)
+		fmt.Fprintf(out, "\t\tlabel=%s;\n", dotQuote(pkg))
+		for _, node := range byPackage[pkg] {
+			label := strings.TrimLeft(strings.TrimPrefix(node.ID, pkg), ".#")
+			style := ""
+			if node.External {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(out, "\t\t%s [label=%s%s];\n", dotQuote(node.ID), dotQuote(label), style)
+		}
+		fmt.Fprintln(out, "\t}")
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(out, "\t%s -> %s [label=\"%d\"];\n", dotQuote(edge.Caller), dotQuote(edge.Callee), len(edge.Sites))
+	}
+	fmt.Fprintln(out, "}")
+}
+
+func dotQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
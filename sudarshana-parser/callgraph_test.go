@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCallGraphDeclaredFunctions(t *testing.T) {
+	const src = `package cgs
+
+type Builder struct{ n int }
+
+func New() *Builder { return &Builder{} }
+
+func (b *Builder) With(n int) *Builder { b.n = n; return b }
+
+func (b *Builder) Do() int { return b.n }
+
+func helper() {}
+
+func Run() {
+	New().With(1).Do()
+	helper()
+	println()
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("example.com/cgs", "cgs", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	builder := newCallGraphBuilder()
+	builder.declare(packagePathOf(sources, "example.com/cgs"), sources)
+	for _, source := range sources {
+		builder.add(source)
+	}
+	graph := builder.build([]string{"example.com/cgs"})
+
+	external := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		external[node.ID] = node.External
+	}
+	for _, id := range []string{"example.com/cgs#Run", "example.com/cgs#New", "example.com/cgs#helper", "example.com/cgs.Builder#With", "example.com/cgs.Builder#Do"} {
+		isExternal, present := external[id]
+		if !present {
+			t.Errorf("expected a node for %s, got %v", id, graph.Nodes)
+		} else if isExternal {
+			t.Errorf("expected %s to be declared in the package", id)
+		}
+	}
+}
+
+func TestCallGraphPromotedMethods(t *testing.T) {
+	const src = `package embed
+
+import "sync"
+
+type Base struct{}
+
+func (Base) Close() {}
+
+type Store struct {
+	sync.Mutex
+	Base
+}
+
+func Run(s *Store) {
+	s.Lock()
+	s.Close()
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("example.com/embed", "embed", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	builder := newCallGraphBuilder()
+	builder.declare(packagePathOf(sources, "example.com/embed"), sources)
+	for _, source := range sources {
+		builder.add(source)
+	}
+	graph := builder.build([]string{"example.com/embed"})
+
+	edges := make(map[string]bool)
+	for _, edge := range graph.Edges {
+		edges[edge.Caller+" -> "+edge.Callee] = true
+	}
+	for _, edge := range []string{"example.com/embed#Run -> sync.Mutex#Lock", "example.com/embed#Run -> example.com/embed.Base#Close"} {
+		if !edges[edge] {
+			t.Errorf("expected an edge %s, got %v", edge, edges)
+		}
+	}
+	external := make(map[string]bool)
+	for _, node := range graph.Nodes {
+		external[node.ID] = node.External
+	}
+	if external["example.com/embed.Base#Close"] || !external["sync.Mutex#Lock"] {
+		t.Errorf("expected only sync.Mutex#Lock to be external, got %v", graph.Nodes)
+	}
+}
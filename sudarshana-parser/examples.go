@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"go/ast"
+	"go/doc"
+	"go/format"
+	"go/printer"
+	"go/token"
+	"go/types"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// exampleWeight is how many mined call sites an Example function is worth
+const exampleWeight = 10
+
+// testFunctionKind tags the function the way `go test` sees it
+func testFunctionKind(decl *ast.FuncDecl) string {
+	if nil == decl.Recv {
+		name := decl.Name.String()
+		switch {
+		case isTestName(name, "Test"):
+			return "test"
+		case isTestName(name, "Benchmark"):
+			return "benchmark"
+		case isTestName(name, "Example"):
+			return "example"
+		case isTestName(name, "Fuzz"):
+			return "fuzz"
+		}
+	}
+	return "test"
+}
+
+// isTestName is the same check `go test` does, TestFoo and Test are tests but Testfoo isn't
+func isTestName(name string, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// examples returns the Example functions in the test file with their // Output: blocks
+func examples(fset *token.FileSet, info *types.Info, packagePath string, fileAst *ast.File) []Example {
+	result := make([]Example, 0)
+	for _, example := range doc.Examples(fileAst) {
+		reference, funcName := documentedIdentifier(info, packagePath, example.Name)
+		result = append(result, Example{
+			Name:      "Example" + example.Name + prefixed(example.Suffix, "_"),
+			Reference: reference,
+			Func:      funcName,
+			Code:      exampleCode(fset, example),
+			Output:    example.Output,
+			Unordered: example.Unordered,
+			Weight:    exampleWeight,
+		})
+	}
+	return result
+}
+
+func prefixed(value string, prefix string) string {
+	if value == "" {
+		return ""
+	}
+	return prefix + value
+}
+
+// documentedIdentifier maps the example name to what it documents, Client_Do is the method Do
+// of *Client when Do has a pointer receiver and Client is the type. Without type information the
+// names are taken as they're written.
+func documentedIdentifier(info *types.Info, packagePath string, name string) (string, string) {
+	typeName, funcName := "", name
+	if idx := strings.Index(name, "_"); idx >= 0 {
+		typeName, funcName = name[:idx], name[idx+1:]
+	}
+	if pkg := documentedPackage(info, packagePath); nil != pkg {
+		if obj, ok := pkg.Scope().Lookup(name).(*types.TypeName); ok && typeName == "" {
+			return genericTypeName(obj.Type()), ""
+		}
+		if obj, ok := pkg.Scope().Lookup(typeName).(*types.TypeName); ok {
+			method, _, _ := types.LookupFieldOrMethod(types.NewPointer(obj.Type()), true, pkg, funcName)
+			if fn, ok := method.(*types.Func); ok && nil != fn.Type().(*types.Signature).Recv() {
+				return genericTypeName(fn.Type().(*types.Signature).Recv().Type()), funcName
+			}
+			return genericTypeName(obj.Type()), funcName
+		}
+	}
+	if typeName != "" {
+		return packagePath + "." + typeName, funcName
+	}
+	return packagePath, name
+}
+
+// documentedPackage finds the package the examples document, the package of the file itself or
+// the one an external test imports
+func documentedPackage(info *types.Info, packagePath string) *types.Package {
+	if nil == info {
+		return nil
+	}
+	for _, obj := range info.Defs {
+		if nil != obj && nil != obj.Pkg() && qualifier(obj.Pkg()) == packagePath {
+			return obj.Pkg()
+		}
+	}
+	for _, obj := range info.Uses {
+		if pkgName, ok := obj.(*types.PkgName); ok && qualifier(pkgName.Imported()) == packagePath {
+			return pkgName.Imported()
+		}
+	}
+	return nil
+}
+
+// exampleCode returns the body of the example without the braces, the // Output: comment is
+// part of the body so the pattern shows what the example prints
+func exampleCode(fset *token.FileSet, example *doc.Example) string {
+	buf := &bytes.Buffer{}
+	format.Node(buf, fset, &printer.CommentedNode{Node: example.Code, Comments: example.Comments})
+	code := buf.String()
+	if _, ok := example.Code.(*ast.BlockStmt); ok {
+		code = strings.TrimSuffix(strings.TrimPrefix(code, "{"), "}")
+		lines := strings.Split(strings.Trim(code, "\n"), "\n")
+		for idx, line := range lines {
+			lines[idx] = strings.TrimPrefix(line, "\t")
+		}
+		code = strings.Join(lines, "\n")
+	}
+	return strings.TrimRight(code, "\n")
+}
+
+// examplesMode prints the Example functions of the package in the columns patterns writes,
+// with the code as the template and the weight as the count, so they can be appended to its output
+func examplesMode(root string, inputPackage string, options ParseOptions) {
+	options.IncludeTests = true
+	sources, err := parseInputPackage(root, inputPackage, options)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	writer := csv.NewWriter(os.Stdout)
+	writer.Comma = '\t'
+	for _, source := range sources {
+		for _, example := range source.Examples {
+			row := []string{example.Reference, example.Func, example.Code, strconv.Itoa(example.Weight)}
+			writer.Write(append(row, make([]string, patternExamples)...))
+		}
+	}
+	writer.Flush()
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+func TestExampleNames(t *testing.T) {
+	const src = `package builder_test
+
+func Example() {}
+
+func Example_second() {}
+
+func ExampleNew() {}
+
+func ExampleBuilder_With() {}
+
+func ExampleBuilder_With_chained() {}
+`
+	fset := token.NewFileSet()
+	fileAst, err := parser.ParseFile(fset, "builder_test.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make(map[string]bool)
+	for _, example := range examples(fset, nil, "example.com/builder", fileAst) {
+		names[example.Name] = true
+	}
+	for _, name := range []string{"Example", "Example_second", "ExampleNew", "ExampleBuilder_With", "ExampleBuilder_With_chained"} {
+		if !names[name] {
+			t.Errorf("expected an example named %s, got %v", name, names)
+		}
+	}
+}
+
+func TestExamplesOfExternalTests(t *testing.T) {
+	const src = `package ex
+
+type Client struct{}
+
+func (c *Client) Do() {}
+
+type Reader struct{}
+
+func Get() {}
+`
+	const test = `package ex_test
+
+import "example.com/ex"
+
+func ExampleClient_Do() { new(ex.Client).Do() }
+
+func ExampleReader() { _ = ex.Reader{} }
+
+func ExampleGet() { ex.Get() }
+`
+	directory := writeFiles(t, map[string]string{"go.mod": "module example.com/ex\n", "ex.go": src, "ex_test.go": test})
+	sources := parsePackage("example.com/ex", "ex", directory, nil, Meta{}, []string{"ex.go", "ex_test.go"}, ParseOptions{IncludeTests: true})
+
+	var external SourceFile
+	for _, source := range sources {
+		if source.File == "ex_test.go" {
+			external = source
+		}
+	}
+	if external.Package != "ex_test" {
+		t.Errorf("expected the external test to be in package ex_test, got %q", external.Package)
+	}
+	for _, expr := range external.Exprs {
+		if expr.Scope().Package != "example.com/ex_test" {
+			t.Errorf("expected the external test to be scoped to example.com/ex_test, got %q", expr.Scope().Package)
+		}
+	}
+	documented := make(map[string][2]string)
+	for _, example := range external.Examples {
+		documented[example.Name] = [2]string{example.Reference, example.Func}
+	}
+	for name, expected := range map[string][2]string{
+		"ExampleClient_Do": {"*example.com/ex.Client", "Do"},
+		"ExampleReader":    {"example.com/ex.Reader", ""},
+		"ExampleGet":       {"example.com/ex", "Get"},
+	} {
+		if documented[name] != expected {
+			t.Errorf("expected %s to document %v, got %v", name, expected, documented[name])
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"go/ast"
+	"go/types"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Imports maps the names a file refers to its imported packages by to their import paths. It's
+// read from the import block of the file alone, so it answers even when the type checker
+// couldn't load the packages.
+type Imports struct {
+	// Names has the alias, or the package name we expect, of every named import
+	Names map[string]string
+	// Dot has the packages imported with ., their identifiers are used unqualified
+	Dot []string
+}
+
+// importsOf reads the import block of the file, the vendor prefix of a path is dropped
+func importsOf(fileAst *ast.File) Imports {
+	imports := Imports{Names: make(map[string]string)}
+	for _, spec := range fileAst.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil || importPath == "C" {
+			continue
+		}
+		importPath = stripVendorPath(importPath)
+		name := importName(importPath)
+		if nil != spec.Name {
+			name = spec.Name.Name
+		}
+		switch name {
+		case ".":
+			imports.Dot = append(imports.Dot, importPath)
+		case "_":
+			// imported for its side effects, it binds no name
+		default:
+			imports.Names[name] = importPath
+		}
+	}
+	return imports
+}
+
+// resolve returns the import path of the package the file knows by the name
+func (imports Imports) resolve(name string) (string, bool) {
+	importPath, present := imports.Names[name]
+	return importPath, present
+}
+
+// importName guesses the name of the package from its import path, the way goimports does -
+// the major version of github.com/go-redis/redis/v8 and gopkg.in/yaml.v2 isn't part of the
+// name, and neither is the go- of github.com/mattn/go-sqlite3
+func importName(importPath string) string {
+	name := path.Base(importPath)
+	if isMajorVersion(name) && path.Dir(importPath) != "." {
+		name = path.Base(path.Dir(importPath))
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if idx := strings.IndexFunc(name, func(r rune) bool {
+		return !(r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r))
+	}); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
+}
+
+// isMajorVersion tells the v2 of a major version suffix from a package named like v1beta1
+func isMajorVersion(element string) bool {
+	if len(element) < 2 || element[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(element[1:])
+	return err == nil
+}
+
+// resolveImports fills in what the type checker couldn't for the packages it failed to
+// import - qualified identifiers like yaml.Unmarshal get the package of their import and
+// the unqualified identifiers of dot imports, Expect(x) or Options{}, get a function, type
+// or variable of the first dot import that failed, as the ones that loaded would have
+// resolved them. Identifiers the type checker did resolve, locals shadowing an import
+// included, are kept, except for the placeholder packages of failed imports which are named
+// after the last element of their path, v8 for github.com/go-redis/redis/v8.
+func resolveImports(fileAst *ast.File, info *types.Info) {
+	if nil == info || nil == fileAst {
+		return
+	}
+	imports := importsOf(fileAst)
+	packages := make(map[string]*types.Package)
+	packageOf := func(importPath string, name string) *types.Package {
+		if pkg, present := packages[importPath]; present {
+			return pkg
+		}
+		pkg := types.NewPackage(importPath, name)
+		packages[importPath] = pkg
+		return pkg
+	}
+	unresolved := func(ident *ast.Ident) bool {
+		return nil == info.Uses[ident] && nil == info.Defs[ident]
+	}
+
+	ast.Inspect(fileAst, func(node ast.Node) bool {
+		if n, ok := node.(*ast.SelectorExpr); ok {
+			ident, ok := n.X.(*ast.Ident)
+			if !ok || !(unresolved(ident) || failedImport(info.Uses[ident])) {
+				return true
+			}
+			if importPath, ok := imports.resolve(ident.Name); ok {
+				info.Uses[ident] = types.NewPkgName(ident.Pos(), nil, ident.Name, packageOf(importPath, importName(importPath)))
+			}
+		}
+		return true
+	})
+
+	importPath, ok := failedDotImport(fileAst, info, imports)
+	if !ok {
+		return
+	}
+	pkg := packageOf(importPath, importName(importPath))
+	roles := identRoles(fileAst)
+	ast.Inspect(fileAst, func(node ast.Node) bool {
+		ident, ok := node.(*ast.Ident)
+		if !ok || !ident.IsExported() || !unresolved(ident) {
+			return true
+		}
+		switch roles[ident] {
+		case roleFunc:
+			info.Uses[ident] = types.NewFunc(ident.Pos(), pkg, ident.Name, nil)
+		case roleType:
+			typeName := types.NewTypeName(ident.Pos(), pkg, ident.Name, nil)
+			types.NewNamed(typeName, types.Typ[types.Invalid], nil)
+			info.Uses[ident] = typeName
+		case roleValue:
+			info.Uses[ident] = types.NewVar(ident.Pos(), pkg, ident.Name, nil)
+		}
+		return true
+	})
+}
+
+// failedDotImport returns the first dot import of the file the type checker couldn't load
+func failedDotImport(fileAst *ast.File, info *types.Info, imports Imports) (string, bool) {
+	loaded := make(map[string]bool)
+	for _, spec := range fileAst.Imports {
+		if nil == spec.Name || spec.Name.Name != "." {
+			continue
+		}
+		if pkgName, ok := info.Defs[spec.Name].(*types.PkgName); ok && pkgName.Imported().Complete() {
+			loaded[stripVendorPath(pkgName.Imported().Path())] = true
+		}
+	}
+	for _, importPath := range imports.Dot {
+		if !loaded[importPath] {
+			return importPath, true
+		}
+	}
+	return "", false
+}
+
+// the roles an unqualified identifier can have, told by where it's written
+const (
+	roleValue = iota
+	roleFunc
+	roleType
+	roleName
+)
+
+// identRoles tells the identifiers called and the ones written as a type apart from the values.
+// Field names, of selectors and of the keys of literals, aren't package level identifiers.
+func identRoles(fileAst *ast.File) map[*ast.Ident]int {
+	roles := make(map[*ast.Ident]int)
+	var typeOf func(expr ast.Expr)
+	typeOf = func(expr ast.Expr) {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			roles[e] = roleType
+		case *ast.StarExpr:
+			typeOf(e.X)
+		case *ast.ArrayType:
+			typeOf(e.Elt)
+		case *ast.MapType:
+			typeOf(e.Key)
+			typeOf(e.Value)
+		case *ast.ChanType:
+			typeOf(e.Value)
+		case *ast.Ellipsis:
+			typeOf(e.Elt)
+		}
+	}
+	ast.Inspect(fileAst, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.CallExpr:
+			if ident, ok := n.Fun.(*ast.Ident); ok {
+				roles[ident] = roleFunc
+			}
+		case *ast.CompositeLit:
+			typeOf(n.Type)
+			for _, elt := range n.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					if key, ok := kv.Key.(*ast.Ident); ok {
+						roles[key] = roleName
+					}
+				}
+			}
+		case *ast.Field:
+			typeOf(n.Type)
+		case *ast.ValueSpec:
+			typeOf(n.Type)
+		case *ast.TypeSpec:
+			typeOf(n.Type)
+		case *ast.TypeAssertExpr:
+			typeOf(n.Type)
+		case *ast.SelectorExpr:
+			roles[n.Sel] = roleName
+		}
+		return true
+	})
+	return roles
+}
+
+// failedImport tells the placeholder the type checker declares for a package it couldn't import
+func failedImport(obj types.Object) bool {
+	pkgName, ok := obj.(*types.PkgName)
+	return ok && !pkgName.Imported().Complete()
+}
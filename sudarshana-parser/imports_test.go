@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestDotImports(t *testing.T) {
+	const src = `package dots
+
+import (
+	. "strings"
+
+	. "example.com/missing/gomega"
+)
+
+func run(s string) {
+	Expect(ToUpper(s)).To(Equal(Default))
+	_ = &Options{Name: s}
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("example.com/dots", "dots", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	callees := make(map[string]string)
+	for _, source := range sources {
+		walkCalls(source.Exprs, func(call Func) {
+			callees[call.Name] = call.Callee
+		})
+	}
+	for name, callee := range map[string]string{
+		"Expect":  "example.com/missing/gomega#Expect",
+		"Equal":   "example.com/missing/gomega#Equal",
+		"ToUpper": "strings#ToUpper",
+	} {
+		if callees[name] != callee {
+			t.Errorf("expected %s to call %s, got %q", name, callee, callees[name])
+		}
+	}
+
+	var options ConstructStruct
+	for _, expr := range sources[0].Exprs {
+		if assignment, ok := expr.(Assignment); ok {
+			options, _ = assignment.Rights[0].(ConstructStruct)
+		}
+	}
+	if options.Reference != "example.com/missing/gomega.Options" {
+		t.Errorf("expected the literal to be a gomega.Options, got %q", options.Reference)
+	}
+}
@@ -3,11 +3,14 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"time"
 )
 
 type GuruWhatResult struct {
@@ -15,24 +18,61 @@ type GuruWhatResult struct {
 	Package string `json:"importpath"`
 }
 
-type GuruPackageReference_Package struct {
-	Path string `json:"path"`
-}
-type GuruPackageReference struct {
-	Desc    string                        `json:"desc"`
-	Pos     string                        `json:"pos"`
-	Detail  string                        `json:"detail"`
-	Package *GuruPackageReference_Package `json:"package"`
-}
-
 func main() {
-	args := os.Args[1:]
+	root := flag.String("root", ".", "module, workspace or GOPATH directory used to resolve the packages")
+	skipVendor := flag.Bool("skip-vendor", true, "skip vendor directories in parsetree mode")
+	skipGenerated := flag.Bool("skip-generated", false, "skip generated files")
+	includeTests := flag.Bool("tests", false, "parse _test.go files as well, functions are tagged as test, benchmark or example")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of packages parsed in parallel in batch mode")
+	format := flag.String("format", "ndjson", "output format of the parse modes: ndjson, csv, tsv or parquet (csv, tsv and parquet have a row per call site), json or dot for callgraph")
+	out := flag.String("out", "-", "file to write the output to, or the directory of the shards with -shard-size")
+	shardSize := flag.Int("shard-size", 0, "start a new part-NNNNN file in the -out directory every n source files")
+	compress := flag.Bool("gzip", false, "gzip the output")
+	editor := flag.String("editor", "all", "editor to write snippets for: vscode, ultisnips, yasnippet or all")
+	packageList := flag.String("packages", "input_packages", "packages to write a snippet set for in snippets mode")
+	top := flag.Int("top", 3, "number of templates of every function turned into snippets")
+	targets := flag.String("targets", "host", "GOOS/GOARCH list the build constraints are evaluated for, host, all or any to parse every file")
+	skipCgo := flag.Bool("skip-cgo", false, "skip the files that import \"C\"")
+	cacheDir := flag.String("cache", "", "directory to cache the parsed packages in, unchanged packages are replayed from it")
+	maxAge := flag.Duration("max-age", 30*24*time.Hour, "prune removes the cache entries not used for this long")
+	manifest := flag.String("manifest", "", "tab separated file of repo, stars and forks used to fill the meta of the parsed files")
+	flag.Usage = usage
+	flag.Parse()
+	args := flag.Args()
 
 	if len(args) != 2 {
-		fmt.Printf("sudarshana [mode=ranks] [file]")
+		flag.Usage()
 		os.Exit(2)
 	}
 	mode, file := args[0], args[1]
+	options := ParseOptions{
+		SkipVendor:    *skipVendor,
+		SkipGenerated: *skipGenerated,
+		IncludeTests:  *includeTests,
+		SkipCgo:       *skipCgo,
+	}
+	targetList, err := parseTargets(*targets)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	options.Targets = targetList
+	metadata, err := NewMetaResolver(*manifest)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	options.Metadata = metadata
+	if *cacheDir != "" && mode != "prune" {
+		options.Cache, err = NewCache(*cacheDir, *root)
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+	}
+	sinkOptions := SinkOptions{
+		Format:    *format,
+		Out:       *out,
+		ShardSize: *shardSize,
+		Gzip:      *compress,
+	}
 	// fmt.Printf("mode=%s\n", mode)
 	// fmt.Printf("file=%s\n", file)
 
@@ -43,16 +83,80 @@ func main() {
 	case "popular":
 		panic("TODO: Yet to implement")
 	case "parse":
-		parse(file)
+		sink := openSinkOrExit(sinkOptions)
+		parse(*root, file, options, sink)
+		closeSink(sink)
+	case "parsetree":
+		sink := openSinkOrExit(sinkOptions)
+		parsetree(file, options, sink)
+		closeSink(sink)
+	case "batch":
+		sink := openSinkOrExit(sinkOptions)
+		batch(*root, file, *workers, options, sink)
+		closeSink(sink)
+	case "examples":
+		examplesMode(*root, file, options)
+	case "patterns":
+		patternsMode(file)
+	case "snippets":
+		snippetDir := *out
+		if snippetDir == "-" {
+			snippetDir = "snippets"
+		}
+		snippetsMode(file, SnippetOptions{Editor: *editor, Packages: *packageList, Out: snippetDir, Top: *top})
+	case "prune":
+		pruneCache(file, *maxAge)
+	case "callgraph":
+		callgraphMode(*root, file, *format, *out, options)
+	case "outline":
+		outlineMode(*root, file)
 	case "parsefile":
 		fileloc := filepath.Base(file)
 		dir := filepath.Dir(file)
-		parsefile("", dir, fileloc)
+		sink := openSinkOrExit(sinkOptions)
+		parsefile("", dir, fileloc, options, sink)
+		closeSink(sink)
 	default:
 		fmt.Printf("Mode=%s is not recognized", mode)
 		os.Exit(2)
 	}
+	options.Cache.printStats(os.Stderr)
+
+}
+
+func usage() {
+	fmt.Fprint(flag.CommandLine.Output(), `usage: sudarshana [flags] mode argument
+
+modes:
+  ranks file                 print the methods of the package of the file sorted by use
+  parse package[@version]    parse a package, resolved from -root
+  parsefile file             parse a single file
+  parsetree directory        parse every package in the directory
+  batch file                 parse the packages listed in the file, - for stdin
+  examples package           print the Example functions of the package as patterns
+  patterns file              count the calls of parse output by template, - for stdin
+  snippets file              turn the output of patterns into editor snippets
+  callgraph package|file     connect the functions of a package or a list of them to their calls
+  outline package|file       print the declarations of a package or a file
+  prune directory            remove the stale entries of a -cache directory
 
+flags:
+`)
+	flag.PrintDefaults()
+}
+
+func openSinkOrExit(options SinkOptions) Sink {
+	sink, err := newSink(options)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	return sink
+}
+
+func closeSink(sink Sink) {
+	if err := sink.Close(); err != nil {
+		log.Fatalf("%q", err)
+	}
 }
 
 // This is for sortedCompletitions
@@ -79,31 +183,3 @@ func ranks(inputFile string) string {
 	// fmt.Printf("%s", output)
 	return string(output)
 }
-
-func guru_describe(query string) *GuruPackageReference {
-	cmd := exec.Command("guru", "-json", "describe", query)
-	var out bytes.Buffer
-	cmd.Stdout = &out
-	err := cmd.Run()
-	// if err != nil {
-	// log.Fatal(err)
-	// }
-	var result *GuruPackageReference
-	if err == nil {
-		json.Unmarshal(out.Bytes(), &result)
-	}
-	// if err != nil {
-	// log.Fatal(err)
-	// }
-	// if result.Package == "" {
-	// 	return ""
-	// }
-	// // TODO: result now has the Package which should be used to identify the sorted list of methods for the package
-	// output, err := json.Marshal(result)
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-	// // fmt.Printf("%s", output)
-	// return string(output)
-	return result
-}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// defaultSource is what we've always reported when we can't tell where the code is from
+const defaultSource = "github.com"
+
+// licenseFiles are the names we look for, in the module directory first and then up to the repository root
+var licenseFiles = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "COPYING", "COPYING.md", "COPYING.txt"}
+
+// licenseMarkers identify the common licenses by a phrase from their text, the more specific
+// ones come first. The version of the GPL family is read from the text.
+var licenseMarkers = []struct {
+	spdx   string
+	phrase string
+}{
+	{"AGPL-3.0", "GNU AFFERO GENERAL PUBLIC LICENSE"},
+	{"LGPL", "GNU LESSER GENERAL PUBLIC LICENSE"},
+	{"GPL", "GNU GENERAL PUBLIC LICENSE"},
+	{"Apache-2.0", "Apache License"},
+	{"MPL-2.0", "Mozilla Public License Version 2.0"},
+	{"BSD-3-Clause", "Neither the name of"},
+	{"BSD-2-Clause", "Redistributions in binary form must reproduce"},
+	{"MIT", "Permission is hereby granted, free of charge"},
+	{"ISC", "Permission to use, copy, modify, and/or distribute this software for any"},
+	{"Unlicense", "This is free and unencumbered software released into the public domain"},
+}
+
+// Popularity is the stars and forks of a repository from the manifest
+type Popularity struct {
+	Stars int
+	Forks int
+}
+
+// MetaResolver fills the Meta of the parsed files from the working copy they're in. The
+// results are cached by directory, so a repository is only inspected once however many of
+// its packages are parsed. It's safe to use from the batch workers.
+type MetaResolver struct {
+	manifest map[string]Popularity
+	mutex    sync.Mutex
+	metas    map[string]Meta
+	repos    map[string]Meta
+}
+
+// NewMetaResolver returns a resolver with the stars and forks from the manifest, if any
+func NewMetaResolver(manifestFile string) (*MetaResolver, error) {
+	resolver := &MetaResolver{
+		manifest: make(map[string]Popularity),
+		metas:    make(map[string]Meta),
+		repos:    make(map[string]Meta),
+	}
+	if manifestFile == "" {
+		return resolver, nil
+	}
+	manifest, err := readManifest(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	resolver.manifest = manifest
+	return resolver, nil
+}
+
+// readManifest reads one repository per line as repo, stars and forks separated by tabs,
+// ignoring empty lines and # comments. The repo can be written as a URL or as github.com/owner/name.
+func readManifest(manifestFile string) (map[string]Popularity, error) {
+	file, err := os.Open(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	manifest := make(map[string]Popularity)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		popularity := Popularity{}
+		if len(fields) > 1 {
+			popularity.Stars, _ = strconv.Atoi(strings.TrimSpace(fields[1]))
+		}
+		if len(fields) > 2 {
+			popularity.Forks, _ = strconv.Atoi(strings.TrimSpace(fields[2]))
+		}
+		manifest[normalizeRepo(strings.TrimSpace(fields[0]))] = popularity
+	}
+	return manifest, scanner.Err()
+}
+
+// metaOf returns the Meta for the files in the directory. A nil resolver (or a directory
+// we know nothing about) only has the Source. Outside of a git working copy, the go.mod and
+// the license are looked for up to moduleDir, the root of the module or the tree we parse.
+func (r *MetaResolver) metaOf(directory string, module *Module, moduleDir string) Meta {
+	if nil == r {
+		return Meta{Source: defaultSource}
+	}
+	directory, err := filepath.Abs(directory)
+	if err != nil {
+		return Meta{Source: defaultSource}
+	}
+
+	r.mutex.Lock()
+	meta, seen := r.metas[directory]
+	r.mutex.Unlock()
+	if seen {
+		return meta
+	}
+
+	toplevel := gitOutput(directory, "rev-parse", "--show-toplevel")
+	if toplevel != "" {
+		r.mutex.Lock()
+		repoMeta, seen := r.repos[toplevel]
+		r.mutex.Unlock()
+		if !seen {
+			repoMeta = gitMeta(toplevel)
+			r.mutex.Lock()
+			r.repos[toplevel] = repoMeta
+			r.mutex.Unlock()
+		}
+		meta = repoMeta
+	}
+	if meta.Repo == "" && nil != module {
+		// the module cache isn't a git repository, but the module path usually is the repository
+		meta.Repo = repoOfModule(module.Path)
+	}
+	if meta.Source == "" {
+		meta.Source = defaultSource
+		if meta.Repo != "" {
+			meta.Source = strings.SplitN(normalizeRepo(meta.Repo), "/", 2)[0]
+		} else if nil != module && strings.Contains(strings.SplitN(module.Path, "/", 2)[0], ".") {
+			// a module from a host we don't know the repositories of is still from that host
+			meta.Source = strings.SplitN(module.Path, "/", 2)[0]
+		}
+	}
+
+	boundary := toplevel
+	if boundary == "" && moduleDir != "" {
+		if root, err := filepath.Abs(moduleDir); err == nil && isWithin(directory, root) {
+			boundary = root
+		}
+	}
+	if boundary == "" {
+		boundary = directory
+	}
+	if gomod, found := findUp(directory, boundary, "go.mod"); found {
+		meta.Module, meta.GoVersion, _ = readGoMod(gomod)
+		// the license is usually next to the go.mod, it's the one of the whole repository otherwise
+		meta.License = findLicense(filepath.Dir(gomod), boundary)
+	} else {
+		meta.License = findLicense(directory, boundary)
+	}
+	if popularity, present := r.manifest[normalizeRepo(meta.Repo)]; present && meta.Repo != "" {
+		meta.Stars = popularity.Stars
+		meta.Forks = popularity.Forks
+	}
+
+	r.mutex.Lock()
+	r.metas[directory] = meta
+	r.mutex.Unlock()
+	return meta
+}
+
+// gitMeta reads the origin remote and the HEAD commit of the working copy
+func gitMeta(toplevel string) Meta {
+	meta := Meta{}
+	if remote := gitOutput(toplevel, "config", "--get", "remote.origin.url"); remote != "" {
+		repo := normalizeRepo(remote)
+		meta.Source = strings.SplitN(repo, "/", 2)[0]
+		meta.Repo = "https://" + repo
+	}
+	meta.Commit = gitOutput(toplevel, "rev-parse", "HEAD")
+	if meta.Commit != "" {
+		meta.CommitDate = gitOutput(toplevel, "log", "-1", "--format=%cI", "HEAD")
+	}
+	return meta
+}
+
+// gitOutput runs the git command in the directory, an error (git missing, not a working
+// copy, no commits yet) is the same as no output
+func gitOutput(directory string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-C", directory}, args...)...)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out.String())
+}
+
+// normalizeRepo turns the remote URL into host/owner/name, so https://github.com/gin-gonic/gin.git,
+// git@github.com:gin-gonic/gin.git and github.com/gin-gonic/gin are all github.com/gin-gonic/gin
+func normalizeRepo(remote string) string {
+	repo := strings.TrimSpace(remote)
+	if parsed, err := url.Parse(repo); err == nil && parsed.Host != "" {
+		repo = parsed.Host + parsed.Path
+	} else if idx := strings.Index(repo, ":"); idx >= 0 && !strings.Contains(repo[:idx], "/") {
+		// scp-like syntax, git@github.com:owner/name.git
+		repo = repo[:idx] + "/" + repo[idx+1:]
+		if at := strings.LastIndex(repo[:idx], "@"); at >= 0 {
+			repo = repo[at+1:]
+		}
+	}
+	repo = strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	return strings.ToLower(repo)
+}
+
+// repoOfModule returns the repository of modules hosted on the well known forges, and of
+// the golang.org/x and gopkg.in vanity paths which map to a repository by a fixed rule
+func repoOfModule(modulePath string) string {
+	parts := strings.Split(modulePath, "/")
+	switch {
+	case len(parts) >= 3 && (parts[0] == "github.com" || parts[0] == "gitlab.com" || parts[0] == "bitbucket.org"):
+		return "https://" + strings.Join(parts[:3], "/")
+	case len(parts) >= 3 && parts[0] == "golang.org" && parts[1] == "x":
+		return "https://go.googlesource.com/" + parts[2]
+	case len(parts) >= 2 && parts[0] == "gopkg.in":
+		// gopkg.in/yaml.v2 is github.com/go-yaml/yaml, gopkg.in/user/pkg.v1 is github.com/user/pkg
+		name := parts[len(parts)-1]
+		if idx := strings.Index(name, ".v"); idx > 0 && len(parts) <= 3 {
+			name = name[:idx]
+			if len(parts) == 2 {
+				return "https://github.com/go-" + name + "/" + name
+			}
+			return "https://github.com/" + parts[1] + "/" + name
+		}
+	}
+	return ""
+}
+
+// isWithin tells if the directory is the root or one of its subdirectories
+func isWithin(directory string, root string) bool {
+	rel, err := filepath.Rel(root, directory)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// findUp looks for the file in the directory and its parents, up to the boundary
+func findUp(directory string, boundary string, name string) (string, bool) {
+	for dir := directory; ; dir = filepath.Dir(dir) {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path, true
+		}
+		if dir == boundary || dir == filepath.Dir(dir) {
+			return "", false
+		}
+	}
+}
+
+// findLicense returns the SPDX identifier of the first license file found from the directory
+// up to the boundary. Licenses we can't identify are reported as "other".
+func findLicense(directory string, boundary string) string {
+	for dir := directory; ; dir = filepath.Dir(dir) {
+		for _, name := range licenseFiles {
+			content, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err == nil {
+				return identifyLicense(string(content))
+			}
+		}
+		if dir == boundary || dir == filepath.Dir(dir) {
+			return ""
+		}
+	}
+}
+
+func identifyLicense(content string) string {
+	for _, marker := range licenseMarkers {
+		if !strings.Contains(content, marker.phrase) {
+			continue
+		}
+		switch marker.spdx {
+		case "LGPL":
+			if strings.Contains(content, "Version 2.1") {
+				return "LGPL-2.1"
+			}
+			return "LGPL-3.0"
+		case "GPL":
+			if strings.Contains(content, "Version 3") {
+				return "GPL-3.0"
+			}
+			return "GPL-2.0"
+		}
+		return marker.spdx
+	}
+	return "other"
+}
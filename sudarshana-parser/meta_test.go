@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+func TestRepoOfModule(t *testing.T) {
+	for modulePath, repo := range map[string]string{
+		"github.com/gin-gonic/gin":         "https://github.com/gin-gonic/gin",
+		"github.com/go-redis/redis/v8":     "https://github.com/go-redis/redis",
+		"golang.org/x/crypto":              "https://go.googlesource.com/crypto",
+		"gopkg.in/yaml.v2":                 "https://github.com/go-yaml/yaml",
+		"gopkg.in/natefinch/lumberjack.v2": "https://github.com/natefinch/lumberjack",
+		"go.uber.org/zap":                  "",
+	} {
+		if got := repoOfModule(modulePath); got != repo {
+			t.Errorf("repoOfModule(%q) = %q, expected %q", modulePath, got, repo)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// GoListModule is the module information reported by `go list -json` and `go mod download -json`
+type GoListModule struct {
+	Path      string        `json:"Path"`
+	Version   string        `json:"Version"`
+	Dir       string        `json:"Dir"`
+	GoVersion string        `json:"GoVersion"`
+	Main      bool          `json:"Main"`
+	Replace   *GoListModule `json:"Replace"`
+	Error     interface{}   `json:"Error"`
+}
+
+// GoListPackageError is the error reported by `go list -e -json` for a package that couldn't be loaded
+type GoListPackageError struct {
+	Err string `json:"Err"`
+}
+
+// GoListPackage is the subset of `go list -json` output we need to locate a package on disk
+type GoListPackage struct {
+	Dir        string              `json:"Dir"`
+	ImportPath string              `json:"ImportPath"`
+	Name       string              `json:"Name"`
+	Module     *GoListModule       `json:"Module"`
+	Error      *GoListPackageError `json:"Error"`
+}
+
+// goList runs `go list` on the given patterns from the root directory. Running it through
+// the go command means go.mod, go.work, replace directives and vendor directories are all
+// honoured the same way the go build would.
+func goList(root string, patterns ...string) ([]GoListPackage, error) {
+	args := append([]string{"list", "-e", "-json"}, patterns...)
+	cmd := exec.Command("go", args...)
+	cmd.Dir = root
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go list %s failed: %v: %s", strings.Join(patterns, " "), err, stderr.String())
+	}
+
+	packages := make([]GoListPackage, 0)
+	decoder := json.NewDecoder(&out)
+	for {
+		var pkg GoListPackage
+		err := decoder.Decode(&pkg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		packages = append(packages, pkg)
+	}
+	return packages, nil
+}
+
+// goModDownload fetches the module into the module cache and returns its location
+func goModDownload(root string, modulePath string, version string) (*GoListModule, error) {
+	cmd := exec.Command("go", "mod", "download", "-json", modulePath+"@"+version)
+	cmd.Dir = root
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	err := cmd.Run()
+	var result *GoListModule
+	if jsonErr := json.Unmarshal(out.Bytes(), &result); jsonErr != nil {
+		if err != nil {
+			return nil, err
+		}
+		return nil, jsonErr
+	}
+	if nil != result.Error || result.Dir == "" {
+		return nil, fmt.Errorf("go mod download %s@%s failed: %v", modulePath, version, result.Error)
+	}
+	return result, nil
+}
+
+// findPackage locates the package on disk. It's first resolved against the build list of the
+// root directory (module, workspace or GOPATH), and when the package isn't part of it, it's
+// looked up in the module cache by trying each prefix of the import path as the module path.
+// inputPackage can optionally carry a version like github.com/gin-gonic/gin@v1.3.0
+func findPackage(root string, inputPackage string) (*GoListPackage, error) {
+	importPath, version := inputPackage, ""
+	if idx := strings.Index(inputPackage, "@"); idx >= 0 {
+		importPath, version = inputPackage[:idx], inputPackage[idx+1:]
+	}
+
+	if version == "" {
+		packages, err := goList(root, importPath)
+		if err == nil && len(packages) == 1 && nil == packages[0].Error && packages[0].Dir != "" {
+			return &packages[0], nil
+		}
+		version = "latest"
+	}
+
+	modulePath := importPath
+	for {
+		module, err := goModDownload(root, modulePath, version)
+		if err == nil {
+			// the package name is picked up from the files while parsing
+			return &GoListPackage{
+				Dir:        filepath.Join(module.Dir, strings.TrimPrefix(importPath, modulePath)),
+				ImportPath: importPath,
+				Module:     module,
+			}, nil
+		}
+		idx := strings.LastIndex(modulePath, "/")
+		if idx < 0 {
+			return nil, fmt.Errorf("package %s not found in the build list or the module cache", inputPackage)
+		}
+		modulePath = modulePath[:idx]
+	}
+}
+
+// toModule converts the `go list` module information to the one we emit
+func toModule(module *GoListModule) *Module {
+	if nil == module {
+		return nil
+	}
+	m := &Module{
+		Path:    module.Path,
+		Version: module.Version,
+	}
+	if nil != module.Replace {
+		if module.Replace.Version != "" {
+			m.Replace = module.Replace.Path + "@" + module.Replace.Version
+		} else {
+			m.Replace = module.Replace.Path
+		}
+	}
+	return m
+}
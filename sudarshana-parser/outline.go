@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// Outline is the declaration tree of a file or of all the files in a package
+type Outline struct {
+	Path         string        `json:"path"`
+	Package      string        `json:"package"`
+	Declarations []Declaration `json:"declarations"`
+	Errors       []ParseError  `json:"errors,omitempty"`
+}
+
+// outlineMode prints the outline of a single .go file, or of a whole package with the
+// methods of a type grouped under it even when they're declared in different files.
+func outlineMode(root string, target string) {
+	var result Outline
+	if strings.HasSuffix(target, ".go") {
+		result = outlineFiles(filepath.Dir(target), []string{filepath.Base(target)})
+		result.Path = target
+	} else {
+		pkg, err := findPackage(root, target)
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+		filenames, err := goFiles(pkg.Dir, ParseOptions{})
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+		result = outlineFiles(pkg.Dir, filenames)
+		result.Path = pkg.ImportPath
+	}
+
+	outAsJSON, err := json.Marshal(result)
+	if err == nil {
+		fmt.Printf("%s\n", string(outAsJSON))
+	}
+}
+
+func outlineFiles(directory string, filenames []string) Outline {
+	result := Outline{}
+	fset := token.NewFileSet()
+	declarations := []Declaration{}
+	for _, filename := range filenames {
+		inputFile := directory + "/" + filename
+		fileAst, err := parser.ParseFile(fset, inputFile, nil, 0)
+		if err != nil {
+			result.Errors = append(result.Errors, toParseErrors(inputFile, err)...)
+		}
+		if nil == fileAst {
+			continue
+		}
+		if result.Package == "" {
+			result.Package = fileAst.Name.String()
+		}
+		for _, declaration := range outline(fset, fileAst) {
+			if len(filenames) > 1 {
+				declaration.File = filename
+			}
+			declarations = append(declarations, declaration)
+		}
+	}
+	result.Declarations = groupMethods(declarations)
+	return result
+}
+
+// groupMethods moves the methods under the declaration of their receiver type. Methods
+// of types that aren't part of the declarations are left at the top level.
+func groupMethods(declarations []Declaration) []Declaration {
+	typeIndex := make(map[string]int)
+	for idx, declaration := range declarations {
+		if declaration.Type == "type" {
+			typeIndex[declaration.Label] = idx
+		}
+	}
+
+	methods := make(map[int][]Declaration)
+	grouped := make([]Declaration, 0, len(declarations))
+	for _, declaration := range declarations {
+		if declaration.Type == "method" {
+			if idx, present := typeIndex[receiverBaseName(declaration.ReceiverType)]; present {
+				methods[idx] = append(methods[idx], declaration)
+				continue
+			}
+		}
+		grouped = append(grouped, declaration)
+	}
+
+	for idx := range grouped {
+		if grouped[idx].Type != "type" {
+			continue
+		}
+		if children, present := methods[typeIndex[grouped[idx].Label]]; present {
+			grouped[idx].Children = append(grouped[idx].Children, children...)
+		}
+	}
+	return grouped
+}
+
+// receiverBaseName strips the pointer and type parameters from the receiver, *List[T] becomes List
+func receiverBaseName(receiverType string) string {
+	name := strings.TrimPrefix(receiverType, "*")
+	if idx := strings.Index(name, "["); idx >= 0 {
+		name = name[:idx]
+	}
+	return name
+}
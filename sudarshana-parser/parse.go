@@ -2,36 +2,40 @@ package main
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"go/ast"
 	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
+	"go/types"
 	"io/ioutil"
 	"log"
-	"os"
 	"strings"
 
-	parseutil "gopkg.in/src-d/go-parse-utils.v1"
+	"github.com/ashwanthkumar/devmerge_2k18/sudarshana-parser/schema"
 )
 
-// Used while resolving paths using Guru
-var importMappingCache map[string]string
-
-func init() {
-	importMappingCache = make(map[string]string)
-}
-
+// Declaration is a node in the outline of a file, types have their fields, interface
+// methods and (when grouped) methods as Children
 type Declaration struct {
-	Label        string        `json:"label"`
-	Type         string        `json:"type"`
-	ReceiverType string        `json:"receiverType,omitempty"`
-	Start        token.Pos     `json:"start"`
-	End          token.Pos     `json:"end"`
-	LineStart    int           `json:"lineStart"`
-	LineEnd      int           `json:"lineEnd"`
-	Children     []Declaration `json:"children,omitempty"`
+	Label        string `json:"label"`
+	Type         string `json:"type"`
+	ReceiverType string `json:"receiverType,omitempty"`
+	// Detail is the type of fields and variables or the signature of functions and methods
+	Detail string `json:"detail,omitempty"`
+	// TypeParams are the type parameters of generic functions and types
+	TypeParams []string `json:"typeParams,omitempty"`
+	// Tag is the struct tag of a field
+	Tag string `json:"tag,omitempty"`
+	// File is set on top level declarations when the outline spans a whole package
+	File string `json:"file,omitempty"`
+	// Start and End are the byte offsets in the file
+	Start     int           `json:"start"`
+	End       int           `json:"end"`
+	LineStart int           `json:"lineStart"`
+	LineEnd   int           `json:"lineEnd"`
+	Children  []Declaration `json:"children,omitempty"`
 }
 
 func getReceiverType(fset *token.FileSet, decl *ast.FuncDecl) (string, error) {
@@ -39,6 +43,11 @@ func getReceiverType(fset *token.FileSet, decl *ast.FuncDecl) (string, error) {
 		return "", nil
 	}
 
+	// a partially parsed func () Foo() {} has a receiver list without a receiver
+	if len(decl.Recv.List) == 0 {
+		return "", fmt.Errorf("method %s has an empty receiver list", decl.Name.String())
+	}
+
 	buf := &bytes.Buffer{}
 	if err := format.Node(buf, fset, decl.Recv.List[0].Type); err != nil {
 		return "", err
@@ -47,111 +56,267 @@ func getReceiverType(fset *token.FileSet, decl *ast.FuncDecl) (string, error) {
 	return buf.String(), nil
 }
 
-func parse(inputPackage string) {
-	importer := parseutil.NewImporter()
-	pkg, err := importer.Import(inputPackage)
-	if err == nil {
-		// fmt.Printf("pkg=%s\n", pkg.Path())
-		// fmt.Printf("Name=%s\n", pkg.Name())
-		dir, err := os.Open(pkg.Path())
+// ParseOptions controls which files are picked up while parsing packages
+type ParseOptions struct {
+	SkipVendor    bool
+	SkipGenerated bool
+	IncludeTests  bool
+	// Targets are the platforms the build constraints are evaluated for, every file is parsed when it's empty
+	Targets []Target
+	// SkipCgo skips the files that import "C"
+	SkipCgo bool
+	// Cache replays the packages whose files didn't change since they were parsed, when it's set
+	Cache *Cache
+	// Metadata fills the Meta of the files, only the Source is set when it's nil
+	Metadata *MetaResolver
+}
+
+func parse(root string, inputPackage string, options ParseOptions, sink Sink) {
+	sources, err := parseInputPackage(root, inputPackage, options)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	for _, source := range sources {
+		writeSource(sink, source)
+	}
+}
+
+// parseInputPackage locates the package (import path, optionally with a version) and parses all its files
+func parseInputPackage(root string, inputPackage string, options ParseOptions) ([]SourceFile, error) {
+	pkg, err := findPackage(root, inputPackage)
+	if err != nil {
+		return nil, err
+	}
+	if pkg.Error != nil {
+		return nil, fmt.Errorf("%s", pkg.Error.Err)
+	}
+	// fmt.Printf("pkg=%s\n", pkg.Dir)
+	// fmt.Printf("Name=%s\n", pkg.Name)
+	filenames, err := goFiles(pkg.Dir, options)
+	if err != nil {
+		return nil, err
+	}
+	module := toModule(pkg.Module)
+	moduleDir := ""
+	if nil != pkg.Module {
+		moduleDir = pkg.Module.Dir
+	}
+	meta := options.Metadata.metaOf(pkg.Dir, module, moduleDir)
+	return cachedParsePackage(pkg.ImportPath, pkg.Name, pkg.Dir, module, meta, filenames, options), nil
+}
+
+func parsefile(packageName string, directory string, filename string, options ParseOptions, sink Sink) {
+	meta := options.Metadata.metaOf(directory, nil, "")
+	// the file was asked for by name, so it's parsed whatever platform it's for
+	options.Targets = nil
+	for _, source := range parsePackage(packageName, packageName, directory, nil, meta, []string{filename}, options) {
+		writeSource(sink, source)
+	}
+}
+
+// writeSource writes the SourceFile to the sink, we can't go on if the output is broken
+func writeSource(sink Sink, source SourceFile) {
+	if err := sink.Write(source); err != nil {
+		log.Fatalf("%q", err)
+	}
+}
+
+// parsePackage parses all the given files of a package together, so the
+// type checker can see every declaration of the package while resolving
+// the references of each file.
+func parsePackage(packagePath string, packageName string, directory string, module *Module, meta Meta, filenames []string, options ParseOptions) []SourceFile {
+	filenames, fileTargets := selectFiles(directory, filenames, options)
+	fset := token.NewFileSet()
+	fileAsts := make([]*ast.File, len(filenames))
+	fileErrors := make([][]ParseError, len(filenames))
+	for idx, filename := range filenames {
+		inputFile := directory + "/" + filename
+		src, err := ioutil.ReadFile(inputFile)
 		if err != nil {
-			log.Fatalf("%q", err)
+			fileErrors[idx] = append(fileErrors[idx], ParseError{Kind: "read", File: inputFile, Message: err.Error()})
+			continue
 		}
-		files, err := dir.Readdir(100)
+
+		// on syntax errors we still get a partial AST, whatever could be parsed is mined
+		// comments are needed for the // Output: of examples
+		fileAst, err := parser.ParseFile(fset, inputFile, src, parser.ParseComments)
 		if err != nil {
-			log.Fatalf("%q", err)
+			fileErrors[idx] = append(fileErrors[idx], toParseErrors(inputFile, err)...)
 		}
-		for _, file := range files {
-			if !file.IsDir() && strings.HasSuffix(file.Name(), ".go") && !strings.HasSuffix(file.Name(), "_test.go") {
-				// path := pkg.Path() + "/" + file.Name()
-				// fmt.Printf("visited file or dir: %q\n", path)
-				parsefile(pkg.Name(), pkg.Path(), file.Name())
-			}
+		if nil == fileAst {
+			continue
+		}
+		fileAsts[idx] = fileAst
+		if packageName == "" && !strings.HasSuffix(filename, "_test.go") {
+			packageName = fileAst.Name.String()
 		}
 	}
-}
-
-func parsefile(packageName string, directory string, filename string) {
-	inputFile := directory + "/" + filename
-	sourceFile, err := os.Open(inputFile)
-	src, err := ioutil.ReadAll(sourceFile)
 
-	fset := token.NewFileSet()
-	fileAst, err := parser.ParseFile(fset, inputFile, src, 0)
-	if err != nil {
-		panic(err)
+	if packageName == "" {
+		// only test files were given
+		for _, fileAst := range fileAsts {
+			if nil != fileAst {
+				packageName = strings.TrimSuffix(fileAst.Name.String(), "_test")
+				break
+			}
+		}
 	}
-
-	visitor := NewASTVisitor(fset, inputFile)
-	ast.Walk(visitor, fileAst)
-	expressions := visitor.NewExprs
-
-	meta := Meta{"github.com"}
-	source := SourceFile{
-		Meta:    meta,
-		Path:    inputFile,
-		Package: packageName,
-		File:    filename,
+	if packagePath == "" {
+		packagePath = packageName
 	}
+	infos := typeCheckTargets(fset, packagePath, packageName, filenames, fileAsts, fileTargets)
 
-	// source.Exprs = ResolvePathsUsingGuru(expressions, inputFile)
-	source.Exprs = expressions
+	sources := make([]SourceFile, 0, len(fileAsts))
+	for idx, fileAst := range fileAsts {
+		inputFile := directory + "/" + filenames[idx]
+
+		filePackagePath, filePackageName := packagePath, packageName
+		if nil != fileAst && isExternalTest(fileAst.Name.String(), packageName) {
+			filePackagePath, filePackageName = packagePath+"_test", fileAst.Name.String()
+		}
+		source := SourceFile{
+			SchemaVersion: schema.Version,
+			Meta:          meta,
+			Path:          inputFile,
+			Package:       filePackageName,
+			File:          filenames[idx],
+			Module:        module,
+			Targets:       fileTargets[filenames[idx]],
+			Errors:        fileErrors[idx],
+		}
 
-	// fmt.Printf("Length=%d\n", len(expressions))
-	// for _, expr := range expressions {
-	// fmt.Printf("%s --- %s -- %s --- %s --- %d\n", expr.Type, expr.VariableType, expr.Scope, expr.Name, fset.Position(expr.Pos).Offset)
-	outAsJSON, err := json.Marshal(source)
-	if err == nil {
-		fmt.Printf("%s\n", string(outAsJSON))
+		if nil != fileAst {
+			source.Constraint = buildConstraint(fileAst)
+			resolveImports(fileAst, infos[idx])
+			visitor := NewASTVisitor(fset, infos[idx], filePackagePath, inputFile)
+			ast.Walk(visitor, fileAst)
+			source.Exprs = visitor.NewExprs
+			source.Errors = append(source.Errors, visitor.Errors...)
+			if visitor.TestFile {
+				source.Test = true
+				source.Examples = examples(fset, infos[idx], packagePath, fileAst)
+			}
+		}
+		sources = append(sources, source)
 	}
-	// }
+	return sources
 }
 
-func ResolvePathsUsingGuru(exprs []Expr, fullPathToFile string) []Expr {
-	updatedExprs := make([]Expr, 0)
-	for _, expr := range exprs {
-		switch e := expr.(type) {
-		case Func:
-			existingReference := e.Reference
-			cacheValue, alreadyInCache := importMappingCache[existingReference]
-			if alreadyInCache {
-				e.Reference = cacheValue
-			} else {
-				// TODO: Invoke Guru and see if we can get the canonical name of this reference
-				query := fmt.Sprintf("%s:#%d", fullPathToFile, e.Pos())
-				packageReference := guru_describe(query)
-				// fmt.Printf("query=%s\noutput=%q\n", query, packageReference)
-				if nil != packageReference && nil != packageReference.Package {
-					if "" != packageReference.Package.Path {
-						resolvedPath := resolveGuruPath(packageReference.Package.Path)
-						importMappingCache[existingReference] = resolvedPath
-						e.Reference = resolvedPath
-					}
-				}
-			}
-			updatedExprs = append(updatedExprs, e)
-		default:
-			updatedExprs = append(updatedExprs, e)
+// isExternalTest tells the files of the package foo_test next to foo, they're a package of their own
+func isExternalTest(name string, packageName string) bool {
+	return name != packageName && strings.HasSuffix(name, "_test")
+}
+
+// filesByPackage groups the parsed files by the name in their package clause
+func filesByPackage(fileAsts []*ast.File) map[string][]*ast.File {
+	packages := make(map[string][]*ast.File)
+	for _, fileAst := range fileAsts {
+		if nil != fileAst {
+			name := fileAst.Name.String()
+			packages[name] = append(packages[name], fileAst)
 		}
 	}
+	return packages
+}
 
-	return updatedExprs
+// toParseErrors converts the error from the parser to one ParseError per syntax error
+func toParseErrors(inputFile string, err error) []ParseError {
+	errorList, ok := err.(scanner.ErrorList)
+	if !ok {
+		return []ParseError{{Kind: "syntax", File: inputFile, Message: err.Error()}}
+	}
+	parseErrors := make([]ParseError, 0, len(errorList))
+	for _, e := range errorList {
+		parseErrors = append(parseErrors, ParseError{
+			Kind:    "syntax",
+			File:    inputFile,
+			Line:    e.Pos.Line,
+			Column:  e.Pos.Column,
+			Offset:  e.Pos.Offset,
+			Message: e.Msg,
+		})
+	}
+	return parseErrors
 }
 
 type ASTVisitor struct {
 	InputFile      string
 	NewExprs       []Expr
+	Errors         []ParseError
 	fset           *token.FileSet
+	info           *types.Info
 	visited        map[string]interface{}
-	currentFunc    string
 	fullPathToFile string
+	// TestFile is set for _test.go files, their functions are tagged as test, benchmark, example or fuzz
+	TestFile bool
+	// ancestors of the node being visited, the node itself is the last one
+	stack []ast.Node
+	// scopes of the enclosing function and function literals, the package scope is the first one
+	scopes []Scope
+	// number of function literals seen so far directly in each of the scopes
+	closures []int
 }
 
-func NewASTVisitor(fset *token.FileSet, fullPathToFile string) *ASTVisitor {
+func NewASTVisitor(fset *token.FileSet, info *types.Info, packagePath string, fullPathToFile string) *ASTVisitor {
 	return &ASTVisitor{
 		fset:           fset,
+		info:           info,
 		visited:        make(map[string]interface{}),
 		fullPathToFile: fullPathToFile,
+		scopes:         []Scope{{Package: packagePath}},
+		closures:       []int{0},
+		TestFile:       strings.HasSuffix(fullPathToFile, "_test.go"),
+	}
+}
+
+// currentScope is the scope of the innermost function or function literal being visited
+func (a *ASTVisitor) currentScope() Scope {
+	return a.scopes[len(a.scopes)-1]
+}
+
+// enterScope starts a new scope for function declarations and literals. Function literals
+// are named like the go compiler does - func1, func2 for the ones directly in the function,
+// func1.1 for the first literal inside func1 and so on.
+func (a *ASTVisitor) enterScope(node ast.Node) {
+	parent := a.currentScope()
+	switch expr := node.(type) {
+	case *ast.FuncDecl:
+		location := locationOf(a.fset, expr)
+		scope := Scope{
+			Package:  parent.Package,
+			Function: expr.Name.String(),
+			Location: &location,
+		}
+		if reciver, err := getReceiverType(a.fset, expr); err == nil {
+			scope.Receiver = reciver
+		}
+		if a.TestFile {
+			scope.Tag = testFunctionKind(expr)
+		}
+		a.scopes = append(a.scopes, scope)
+		a.closures = append(a.closures, 0)
+	case *ast.FuncLit:
+		a.closures[len(a.closures)-1]++
+		count := a.closures[len(a.closures)-1]
+		scope := parent
+		if parent.Closure == "" {
+			scope.Closure = fmt.Sprintf("func%d", count)
+		} else {
+			scope.Closure = fmt.Sprintf("%s.%d", parent.Closure, count)
+		}
+		location := locationOf(a.fset, expr)
+		scope.Location = &location
+		a.scopes = append(a.scopes, scope)
+		a.closures = append(a.closures, 0)
+	}
+}
+
+// leaveScope pops the scope once we're done with the function declaration or literal
+func (a *ASTVisitor) leaveScope(node ast.Node) {
+	switch node.(type) {
+	case *ast.FuncDecl, *ast.FuncLit:
+		a.scopes = a.scopes[:len(a.scopes)-1]
+		a.closures = a.closures[:len(a.closures)-1]
 	}
 }
 
@@ -159,47 +324,132 @@ func (a *ASTVisitor) Key(pos token.Pos) string {
 	return fmt.Sprintf("%d", pos)
 }
 
-func (a *ASTVisitor) Visit(node ast.Node) ast.Visitor {
-	if node != nil {
-		key := a.Key(node.Pos())
-		// fmt.Printf("I'm at offset=%d, key=%s\n", a.fset.Position(node.Pos()).Offset, key)
-		_, seenAlready := a.visited[key]
-		if !seenAlready {
-			switch expr := node.(type) {
-			case *ast.FuncDecl:
-				reciver, err := getReceiverType(a.fset, expr)
-				if err == nil {
-					if reciver != "" {
-						a.currentFunc = reciver + "#" + expr.Name.String()
-					} else {
-						a.currentFunc = expr.Name.String()
-					}
-				}
-			case *ast.GenDecl:
-				a.currentFunc = ""
-			}
-
-			exp := parseNode2(node, a.fset, a.currentFunc, a.fullPathToFile)
-			if nil != exp {
-				a.NewExprs = append(a.NewExprs, exp)
-				for _, pos := range exp.AllPos() {
-					keyForExpr := a.Key(pos)
-					a.visited[keyForExpr] = nil
-				}
-				// fmt.Printf("%v\n", exp)
+// Visit tracks the scopes and statements around the node and extracts its expression. A node
+// we fail on, scope tracking included, is recorded as an error and its children are skipped, so
+// one bad file never takes down the whole run.
+func (a *ASTVisitor) Visit(node ast.Node) (visitor ast.Visitor) {
+	if node == nil {
+		// ast.Walk calls us with nil once it's done with the children of the node on top of the stack
+		a.leaveScope(a.stack[len(a.stack)-1])
+		a.stack = a.stack[:len(a.stack)-1]
+		return a
+	}
+	depth, scopes := len(a.stack), len(a.scopes)
+	defer func() {
+		if r := recover(); r != nil {
+			a.internalError(node, r)
+			// ast.Walk won't call us with nil for a node whose children it skips
+			a.stack = a.stack[:depth]
+			a.scopes = a.scopes[:scopes]
+			a.closures = a.closures[:scopes]
+			visitor = nil
+		}
+	}()
+	a.stack = append(a.stack, node)
+	// a function literal can start at an already visited position, like func() { ... }()
+	a.enterScope(node)
+	key := a.Key(node.Pos())
+	// fmt.Printf("I'm at offset=%d, key=%s\n", a.fset.Position(node.Pos()).Offset, key)
+	_, seenAlready := a.visited[key]
+	if !seenAlready {
+		exp := a.parseNode(node)
+		if nil != exp {
+			exp = withStatement(exp, a.statement())
+			a.NewExprs = append(a.NewExprs, exp)
+			for _, pos := range exp.AllPos() {
+				keyForExpr := a.Key(pos)
+				a.visited[keyForExpr] = nil
 			}
+			// fmt.Printf("%v\n", exp)
 		}
 	}
 	return a
 }
 
-func toIdentText(in ast.Node) (string, bool) {
-	identExpr, ok := in.(*ast.Ident)
-	if ok {
-		return identExpr.String(), true
-	} else {
-		return "", false
+// statement finds the nearest statement around the node on top of the stack that tells us
+// how the expression is used, and how deeply it's nested in blocks within its function.
+// Function literals are a boundary, calls inside `go func() { ... }()` run in the goroutine
+// as plain statements.
+func (a *ASTVisitor) statement() Statement {
+	statement := Statement{}
+	blocks := 0
+	var child ast.Node
+	for idx := len(a.stack) - 1; idx >= 0; idx-- {
+		ancestor := a.stack[idx]
+		if _, isFuncBoundary := ancestor.(*ast.FuncLit); isFuncBoundary {
+			break
+		}
+		if _, isFuncBoundary := ancestor.(*ast.FuncDecl); isFuncBoundary {
+			break
+		}
+		if _, isBlock := ancestor.(*ast.BlockStmt); isBlock {
+			blocks++
+		}
+		if statement.Context == "" {
+			statement.Context = statementContext(ancestor, child)
+		}
+		child = ancestor
 	}
+	// the body of the function itself isn't nesting
+	if blocks > 0 {
+		statement.Depth = blocks - 1
+	}
+	return statement
+}
+
+// statementContext returns the context kind if the node is a statement we're interested in,
+// child is the node on the path to the expression, to tell apart the parts of the statement
+func statementContext(node ast.Node, child ast.Node) string {
+	switch stmt := node.(type) {
+	case *ast.DeferStmt:
+		return "defer"
+	case *ast.GoStmt:
+		return "go"
+	case *ast.ReturnStmt:
+		return "return"
+	case *ast.IfStmt:
+		if nil != child && child == stmt.Init {
+			return "if-init"
+		}
+		if nil != child && child == stmt.Cond {
+			return "if-cond"
+		}
+		return "if"
+	case *ast.ForStmt:
+		return "for"
+	case *ast.RangeStmt:
+		return "range"
+	case *ast.CommClause:
+		return "select-case"
+	case *ast.CaseClause:
+		return "switch-case"
+	}
+	return ""
+}
+
+// parseNode extracts the expression at the node. A partially parsed file can have nodes
+// we don't expect, so a failure is recorded against the node and the walk continues.
+func (a *ASTVisitor) parseNode(node ast.Node) (exp Expr) {
+	defer func() {
+		if r := recover(); r != nil {
+			a.internalError(node, r)
+			exp = nil
+		}
+	}()
+	return parseNode2(node, a.fset, a.info, a.currentScope(), a.fullPathToFile)
+}
+
+// internalError records a panic while handling the node against the node
+func (a *ASTVisitor) internalError(node ast.Node, r interface{}) {
+	position := a.fset.Position(node.Pos())
+	a.Errors = append(a.Errors, ParseError{
+		Kind:    "internal",
+		File:    a.fullPathToFile,
+		Line:    position.Line,
+		Column:  position.Column,
+		Offset:  position.Offset,
+		Message: fmt.Sprintf("%v", r),
+	})
 }
 
 func asSelectorExpr(in ast.Node) (*ast.SelectorExpr, bool) {
@@ -219,117 +469,184 @@ func asKeyValueExpr(in ast.Node) (*ast.KeyValueExpr, bool) {
 		return nil, false
 	}
 }
-func asBasicLit(in ast.Node) (*ast.BasicLit, bool) {
-	basicExpr, ok := in.(*ast.BasicLit)
-	if ok {
-		return basicExpr, true
-	} else {
-		return nil, false
+
+// callKind tells the type conversions and the calls of builtins, len(s) or unsafe.Sizeof(x), from
+// the calls of functions
+func callKind(info *types.Info, fun ast.Expr) (bool, bool) {
+	if nil == info {
+		return false, false
+	}
+	if typeAndValue, ok := info.Types[fun]; ok && typeAndValue.IsType() {
+		return true, false
 	}
+	switch expr := ast.Unparen(fun).(type) {
+	case *ast.Ident:
+		_, builtin := info.Uses[expr].(*types.Builtin)
+		return false, builtin
+	case *ast.SelectorExpr:
+		_, builtin := info.Uses[expr.Sel].(*types.Builtin)
+		return false, builtin
+	}
+	return false, false
 }
 
-func parseNode2(node ast.Node, fset *token.FileSet, scope string, fullPathToFile string) Expr {
+// funcLitName is the name of calls of function literals, it's a keyword so no function has it
+const funcLitName = "func"
+
+func parseNode2(node ast.Node, fset *token.FileSet, info *types.Info, scope Scope, fullPathToFile string) Expr {
 	// expressions := []Expr{}
 	// fmt.Printf("%s -- %v\n", reflect.TypeOf(node), node)
 	switch expr := node.(type) {
 	case *ast.AssignStmt:
-		leftExprs := make([]Expr, 0)
-		for _, lhs := range expr.Lhs {
-			// var lExpression Expr
+		rights := make([]Expr, 0, len(expr.Rhs))
+		for _, rhs := range expr.Rhs {
+			rights = append(rights, parseValue(rhs, fset, info, scope, fullPathToFile))
+		}
+		leftExprs := make([]Expr, 0, len(expr.Lhs))
+		for idx, lhs := range expr.Lhs {
+			var lExpression Expr
 			switch l := lhs.(type) {
 			case *ast.Ident:
-				// ignore the _ names, we don't care mostly
-				if l.String() != "_" {
-					lExpression := Variable{
-						Name:   l.String(),
-						Type:   "variable",
-						Offset: l.Pos(),
-						CScope: scope,
-					}
-
-					switch r := expr.Rhs[0].(type) {
-					case *ast.CompositeLit:
-						switch rType := r.Type.(type) {
+				variable := assignedVariable(fset, l, scope)
+				if rhs, ok := matchingRhs(expr.Rhs, len(expr.Lhs), idx); ok {
+					if lit, _, ok := asCompositeLit(rhs); ok {
+						switch rType := lit.Type.(type) {
 						case *ast.Ident:
-							lExpression.Reference = rType.String()
+							variable.Reference = rType.String()
+						}
+						if resolved, ok := resolveType(info, rhs); ok {
+							variable.Reference = resolved
 						}
 					}
-
-					leftExprs = append(leftExprs, lExpression)
 				}
-			}
-		}
-
-		var rhs Expr
-		switch r := expr.Rhs[0].(type) {
-		case *ast.CompositeLit:
-			switch rType := r.Type.(type) {
-			case *ast.Ident:
-				typeOfVariable := rType
-				createStruct := ConstructStruct{
-					Type:   "constructstruct",
-					Offset: expr.Pos(),
-					CScope: scope,
-					Struct: typeOfVariable.String(),
+				lExpression = variable
+			case *ast.SelectorExpr:
+				if access, ok := fieldAccess(l, fset, info, scope, "write"); ok {
+					lExpression = access
 				}
-				createStruct.KeyValueArgs = make(map[string]string)
-
-				for _, elt := range r.Elts {
-					eltAsKV, ok := asKeyValueExpr(elt)
-					if ok {
-						key, _ := toIdentText(eltAsKV.Key)
-						value, _ := toIdentText(eltAsKV.Value)
-						createStruct.KeyValueArgs[key] = value
-					} else {
-						eltAsBasic, ok := asBasicLit(elt)
-						if ok {
-							createStruct.Args = append(createStruct.Args, eltAsBasic.Value)
-						}
-					}
+			}
+			if nil == lExpression {
+				// m[k], *p or a package level variable, we keep them so every lhs has its rhs
+				lExpression = Variable{
+					Name:     nodeText(fset, lhs),
+					Type:     "variable",
+					Offset:   lhs.Pos(),
+					Location: locationOf(fset, lhs),
+					CScope:   scope,
+					Code:     nodeText(fset, lhs),
 				}
-				rhs = createStruct
 			}
+			leftExprs = append(leftExprs, lExpression)
 		}
 
-		// attempt to parse the values
-		if nil == rhs {
-			rhs = parseNode2(expr.Rhs[0], fset, scope, fullPathToFile)
-		}
 		buf := &bytes.Buffer{}
 		format.Node(buf, fset, expr)
 		assignment := Assignment{
-			Lefts:  leftExprs,
-			CScope: scope,
-			Type:   "assignment",
-			Offset: expr.Pos(),
-			Code:   buf.String(),
+			Lefts:    leftExprs,
+			Rights:   rights,
+			Pairs:    assignmentPairs(len(expr.Lhs), len(expr.Rhs)),
+			Operator: expr.Tok.String(),
+			CScope:   scope,
+			Type:     "assignment",
+			Offset:   expr.Pos(),
+			Location: locationOf(fset, expr),
+			Code:     buf.String(),
 		}
-		if nil != rhs {
-			assignment.Right = rhs
+
+		return assignment
+
+	case *ast.IncDecStmt:
+		// p.Count++ writes the field, like p.Count += 1 does
+		if sel, ok := expr.X.(*ast.SelectorExpr); ok {
+			if access, ok := fieldAccess(sel, fset, info, scope, "write"); ok {
+				return access
+			}
 		}
+		return nil
 
+	case *ast.ValueSpec:
+		// var and const declarations are assignments as well
+		rights := make([]Expr, 0, len(expr.Values))
+		for _, value := range expr.Values {
+			rights = append(rights, parseValue(value, fset, info, scope, fullPathToFile))
+		}
+		operator := "var"
+		leftExprs := make([]Expr, 0, len(expr.Names))
+		for _, name := range expr.Names {
+			variable := assignedVariable(fset, name, scope)
+			if resolved, ok := resolveType(info, name); ok {
+				variable.Reference = resolved
+			} else if nil != expr.Type {
+				variable.Reference = nodeText(fset, expr.Type)
+			}
+			if isConst(info, name) {
+				operator = "const"
+			}
+			leftExprs = append(leftExprs, variable)
+		}
+
+		assignment := Assignment{
+			Lefts:    leftExprs,
+			Rights:   rights,
+			Pairs:    assignmentPairs(len(expr.Names), len(expr.Values)),
+			Operator: operator,
+			CScope:   scope,
+			Type:     "assignment",
+			Offset:   expr.Pos(),
+			Location: locationOf(fset, expr),
+			Code:     nodeText(fset, expr),
+		}
 		return assignment
 
 	case *ast.CallExpr:
 		buf := &bytes.Buffer{}
 		format.Node(buf, fset, expr)
 		f := Func{
-			Type:   "function",
-			CScope: scope,
-			Offset: expr.Pos(),
-			Code:   buf.String(),
+			Type:     "function",
+			CScope:   scope,
+			Offset:   expr.Pos(),
+			Location: locationOf(fset, expr),
+			Code:     buf.String(),
+			Template: templateOf(expr, fset, info),
 		}
-		funSelector, ok := asSelectorExpr(expr.Fun)
+		fun, typeArgs := unwrapInstantiation(expr.Fun, info)
+		for _, typeArg := range typeArgs {
+			f.TypeArgs = append(f.TypeArgs, nodeText(fset, typeArg))
+			f.ExplicitTypeArgs = true
+		}
+		funSelector, ok := asSelectorExpr(fun)
 		if ok {
 			xAsIdent, ok := funSelector.X.(*ast.Ident)
 			if ok {
 				f.Reference = xAsIdent.String()
+			} else {
+				// builder chains and nested receivers, r.HandleFunc("/", h).Methods("GET") or a.b.c.Do()
+				f.Receiver = parseReceiver(funSelector.X, fset, info, scope, fullPathToFile)
 			}
 			f.Name = funSelector.Sel.String()
+			if resolved, ok := resolveSelector(info, funSelector); ok {
+				f.Reference = resolved
+			}
+			if callee, ok := resolveCallee(info, funSelector); ok {
+				f.Callee = callee
+			}
+		} else if funIdent, ok := fun.(*ast.Ident); ok {
+			f.Name = funIdent.String()
+			if callee, ok := resolveFunc(info, funIdent); ok {
+				f.Callee = callee
+			}
+		} else if _, ok := fun.(*ast.FuncLit); ok {
+			// go func() { ... }() and friends
+			f.Name = funcLitName
 		} else {
-			funSelector, _ := expr.Fun.(*ast.Ident)
-			f.Name = funSelector.String()
+			// conversions like []byte(s) and calls of expressions like handlers[i](w, r)
+			f.Name = nodeText(fset, fun)
 		}
+		f.Conversion, f.Builtin = callKind(info, fun)
+		if len(f.TypeArgs) == 0 {
+			f.TypeArgs = inferredTypeArgs(info, fun)
+		}
+		f.ArgTypes = callArguments(expr, fset, info)
 		f.Args = make([]Expr, 0)
 
 		for _, arg := range expr.Args {
@@ -338,32 +655,44 @@ func parseNode2(node ast.Node, fset *token.FileSet, scope string, fullPathToFile
 				buf := &bytes.Buffer{}
 				format.Node(buf, fset, argExpr)
 				v := Variable{
-					Type:   "variable",
-					CScope: scope,
-					Name:   argExpr.String(),
-					Offset: argExpr.Pos(),
-					Code:   buf.String(),
+					Type:     "variable",
+					CScope:   scope,
+					Name:     argExpr.String(),
+					Offset:   argExpr.Pos(),
+					Location: locationOf(fset, argExpr),
+					Code:     buf.String(),
 				}
 				f.Args = append(f.Args, v)
 			default:
-				otherExpr := parseNode2(arg, fset, scope, fullPathToFile)
+				otherExpr := parseNode2(arg, fset, info, scope, fullPathToFile)
 				if nil != otherExpr {
 					f.Args = append(f.Args, otherExpr)
 				}
 			}
 		}
 		return f
+	case *ast.SelectorExpr:
+		if access, ok := fieldAccess(expr, fset, info, scope, "read"); ok {
+			return access
+		}
+	case *ast.CompositeLit:
+		return parseCompositeLit(expr, expr, fset, info, scope, fullPathToFile)
+	case *ast.UnaryExpr:
+		if lit, _, ok := asCompositeLit(expr); ok {
+			return parseCompositeLit(lit, expr, fset, info, scope, fullPathToFile)
+		}
 	case *ast.BasicLit:
-		if "" != scope {
+		if "" != scope.Function {
 			buf := &bytes.Buffer{}
 			format.Node(buf, fset, expr)
 			v := Value{
-				Type:   "constant",
-				CScope: scope,
-				TypeOf: expr.Kind.String(),
-				Value:  expr.Value,
-				Offset: expr.Pos(),
-				Code:   buf.String(),
+				Type:     "constant",
+				CScope:   scope,
+				TypeOf:   expr.Kind.String(),
+				Value:    expr.Value,
+				Offset:   expr.Pos(),
+				Location: locationOf(fset, expr),
+				Code:     buf.String(),
 			}
 			return v
 		}
@@ -372,13 +701,376 @@ func parseNode2(node ast.Node, fset *token.FileSet, scope string, fullPathToFile
 	return nil
 }
 
-func resolveGuruPath(guruPath string) string {
-	if strings.Contains(guruPath, "/vendor/") {
-		// we've a vendored path, filter things before /vendor/ to get the import path
-		return strings.Split(guruPath, "/vendor/")[1]
+// fieldAccess returns the PropertyAccessInStruct if the selector reads or writes a struct field.
+// Package qualified names and method values aren't field accesses, so we rely on the type
+// checker to tell them apart.
+func fieldAccess(sel *ast.SelectorExpr, fset *token.FileSet, info *types.Info, scope Scope, access string) (PropertyAccessInStruct, bool) {
+	if nil == info {
+		return PropertyAccessInStruct{}, false
+	}
+	selection, ok := info.Selections[sel]
+	if !ok || selection.Kind() != types.FieldVal {
+		return PropertyAccessInStruct{}, false
+	}
+	// p.Name and (&p).Name are the same field of the same struct
+	recv := selection.Recv()
+	if pointer, ok := recv.(*types.Pointer); ok {
+		recv = pointer.Elem()
+	}
+	buf := &bytes.Buffer{}
+	format.Node(buf, fset, sel)
+	property := PropertyAccessInStruct{
+		Struct:    typeName(recv),
+		Property:  sel.Sel.String(),
+		FieldType: typeName(selection.Type()),
+		Access:    access,
+		Offset:    sel.Pos(),
+		Location:  locationOf(fset, sel),
+		CScope:    scope,
+		Type:      "property",
+		Code:      buf.String(),
+	}
+	if _, ok := sel.X.(*ast.Ident); !ok {
+		property.Receiver = parseReceiver(sel.X, fset, info, scope, "")
+	}
+	return property, true
+}
+
+// parseReceiver parses the expression a method or field is selected from, so a chain
+// of calls and field accesses is linked through the Receiver of each element
+func parseReceiver(x ast.Expr, fset *token.FileSet, info *types.Info, scope Scope, fullPathToFile string) Expr {
+	for {
+		paren, ok := x.(*ast.ParenExpr)
+		if !ok {
+			break
+		}
+		x = paren.X
+	}
+	switch x.(type) {
+	case *ast.CallExpr, *ast.SelectorExpr:
+		return parseNode2(x, fset, info, scope, fullPathToFile)
+	}
+	return nil
+}
+
+// callArguments describes every argument of the call - its kind, static type and the parameter
+// of the callee it's bound to. Conversions and calls we couldn't type check have no parameters.
+func callArguments(call *ast.CallExpr, fset *token.FileSet, info *types.Info) []Argument {
+	var signature *types.Signature
+	if nil != info {
+		if typeAndValue, ok := info.Types[call.Fun]; ok && !typeAndValue.IsType() {
+			signature, _ = typeAndValue.Type.Underlying().(*types.Signature)
+		}
+	}
+
+	arguments := make([]Argument, 0, len(call.Args))
+	for idx, arg := range call.Args {
+		argument := Argument{
+			Index: idx,
+			Kind:  argumentKind(arg),
+			Code:  nodeText(fset, arg),
+		}
+		argument.Type, _ = resolveType(info, arg)
+		if nil != signature {
+			bindParameter(&argument, signature, call, idx)
+		}
+		arguments = append(arguments, argument)
+	}
+	return arguments
+}
+
+// bindParameter sets the parameter the argument at idx is passed as. The arguments past the
+// last parameter of a variadic function are all bound to it, and f(g()) binds the results of
+// g to all the parameters.
+func bindParameter(argument *Argument, signature *types.Signature, call *ast.CallExpr, idx int) {
+	params := signature.Params()
+	if nil == params || params.Len() == 0 {
+		return
+	}
+	if len(call.Args) == 1 && params.Len() > 1 && !signature.Variadic() {
+		names := make([]string, 0, params.Len())
+		for p := 0; p < params.Len(); p++ {
+			names = append(names, params.At(p).Name())
+		}
+		argument.Param = strings.Join(names, ", ")
+		argument.ParamType = typeName(params)
+		return
+	}
+	last := params.Len() - 1
+	if idx < last || (idx == last && !signature.Variadic()) {
+		argument.Param = params.At(idx).Name()
+		argument.ParamType = typeName(params.At(idx).Type())
+		return
+	}
+	if !signature.Variadic() || idx > last && call.Ellipsis.IsValid() {
+		return
+	}
+	argument.Param = params.At(last).Name()
+	argument.ParamType = typeName(params.At(last).Type())
+	if !call.Ellipsis.IsValid() {
+		// a single value of the ...T parameter
+		argument.Variadic = true
+		if slice, ok := params.At(last).Type().(*types.Slice); ok {
+			argument.ParamType = typeName(slice.Elem())
+		}
+	}
+}
+
+// argumentKind tells what's passed - ident, literal, call, selector, funclit, composite or other
+func argumentKind(arg ast.Expr) string {
+	if _, _, ok := asCompositeLit(arg); ok {
+		return "composite"
+	}
+	switch a := arg.(type) {
+	case *ast.ParenExpr:
+		return argumentKind(a.X)
+	case *ast.Ident:
+		return "ident"
+	case *ast.BasicLit:
+		return "literal"
+	case *ast.CallExpr:
+		return "call"
+	case *ast.SelectorExpr:
+		return "selector"
+	case *ast.FuncLit:
+		return "funclit"
+	}
+	return "other"
+}
+
+// unwrapInstantiation strips the explicit type arguments from calls like lo.Map[int, string](...).
+// f[0]() is an index expression too, so we only strip it when the index is a type.
+func unwrapInstantiation(fun ast.Expr, info *types.Info) (ast.Expr, []ast.Expr) {
+	switch index := fun.(type) {
+	case *ast.IndexListExpr:
+		return index.X, index.Indices
+	case *ast.IndexExpr:
+		if nil != info {
+			if typeAndValue, ok := info.Types[index.Index]; ok && typeAndValue.IsType() {
+				return index.X, []ast.Expr{index.Index}
+			}
+		}
+	}
+	return fun, nil
+}
+
+// inferredTypeArgs returns the type arguments the type checker inferred for a call to a generic function
+func inferredTypeArgs(info *types.Info, fun ast.Expr) []string {
+	if nil == info {
+		return nil
+	}
+	var ident *ast.Ident
+	switch f := fun.(type) {
+	case *ast.Ident:
+		ident = f
+	case *ast.SelectorExpr:
+		ident = f.Sel
+	default:
+		return nil
+	}
+	instance, ok := info.Instances[ident]
+	if !ok || nil == instance.TypeArgs {
+		return nil
+	}
+	typeArgs := make([]string, 0, instance.TypeArgs.Len())
+	for idx := 0; idx < instance.TypeArgs.Len(); idx++ {
+		typeArgs = append(typeArgs, typeName(instance.TypeArgs.At(idx)))
+	}
+	return typeArgs
+}
+
+// assignedVariable is the Variable for an identifier on the left hand side of an assignment
+func assignedVariable(fset *token.FileSet, ident *ast.Ident, scope Scope) Variable {
+	return Variable{
+		Name:     ident.String(),
+		Type:     "variable",
+		Offset:   ident.Pos(),
+		Location: locationOf(fset, ident),
+		CScope:   scope,
+		Code:     ident.String(),
+		// results assigned to _ are discarded, we still keep them to know how often that happens
+		Blank: ident.String() == "_",
+	}
+}
+
+// matchingRhs returns the right hand side assigned to the idx-th left hand side. For
+// a, b := f() all of them are assigned from the same call.
+func matchingRhs(rhs []ast.Expr, lefts int, idx int) (ast.Expr, bool) {
+	if len(rhs) == lefts {
+		return rhs[idx], true
 	}
+	if len(rhs) == 1 {
+		return rhs[0], true
+	}
+	return nil, false
+}
 
-	return guruPath
+// assignmentPairs pairs every left hand side with its right hand side
+func assignmentPairs(lefts int, rights int) []AssignmentPair {
+	pairs := make([]AssignmentPair, 0, lefts)
+	for idx := 0; idx < lefts; idx++ {
+		switch {
+		case rights == lefts:
+			pairs = append(pairs, AssignmentPair{Left: idx, Right: idx})
+		case rights == 1:
+			// a, b := f() or v, ok := m[k]
+			pairs = append(pairs, AssignmentPair{Left: idx, Right: 0, Result: idx})
+		default:
+			// var x, y int has no values
+			pairs = append(pairs, AssignmentPair{Left: idx, Right: -1})
+		}
+	}
+	return pairs
+}
+
+// isConst tells if the identifier declares a constant
+func isConst(info *types.Info, ident *ast.Ident) bool {
+	if nil != info {
+		if obj, ok := info.Defs[ident]; ok && nil != obj {
+			_, isConst := obj.(*types.Const)
+			return isConst
+		}
+	}
+	return nil != ident.Obj && ident.Obj.Kind == ast.Con
+}
+
+// asCompositeLit unwraps T{...} and &T{...}, the bool tells if it's a pointer
+func asCompositeLit(in ast.Expr) (*ast.CompositeLit, bool, bool) {
+	switch expr := in.(type) {
+	case *ast.CompositeLit:
+		return expr, false, true
+	case *ast.UnaryExpr:
+		if lit, ok := expr.X.(*ast.CompositeLit); ok && expr.Op == token.AND {
+			return lit, true, true
+		}
+	}
+	return nil, false, false
+}
+
+// parseCompositeLit returns the ConstructStruct for a composite literal of any kind - structs,
+// slices, arrays and maps. node is the literal itself or the &T{...} around it. The type of
+// elided literals like the elements of []T{{...}} comes from the type checker.
+func parseCompositeLit(lit *ast.CompositeLit, node ast.Expr, fset *token.FileSet, info *types.Info, scope Scope, fullPathToFile string) ConstructStruct {
+	_, pointer, _ := asCompositeLit(node)
+	createStruct := ConstructStruct{
+		Type:       "constructstruct",
+		Offset:     node.Pos(),
+		Location:   locationOf(fset, node),
+		CScope:     scope,
+		Pointer:    pointer,
+		Code:       nodeText(fset, node),
+		LiteralPos: lit.Pos(),
+	}
+	if nil != lit.Type {
+		createStruct.Struct = nodeText(fset, lit.Type)
+		switch typ := lit.Type.(type) {
+		case *ast.ArrayType:
+			if nil == typ.Len {
+				createStruct.Kind = "slice"
+			} else {
+				createStruct.Kind = "array"
+			}
+		case *ast.MapType:
+			createStruct.Kind = "map"
+		}
+	}
+	if nil != info {
+		typ := info.TypeOf(lit)
+		if typ == types.Typ[types.Invalid] {
+			// the types of dot imports that failed only have the name resolveImports gave them
+			typ = nil
+			if ident, ok := lit.Type.(*ast.Ident); ok {
+				if obj, ok := info.Uses[ident].(*types.TypeName); ok {
+					typ = obj.Type()
+				}
+			}
+		}
+		if nil != typ {
+			// instantiations of a generic type are all the same type for us, List[int] and List[string] are both List
+			createStruct.Reference = genericTypeName(typ)
+			createStruct.TypeArgs = typeArgsOf(typ)
+			if createStruct.Struct == "" {
+				createStruct.Struct = typeName(typ)
+			}
+			switch typ.Underlying().(type) {
+			case *types.Struct:
+				createStruct.Kind = "struct"
+			case *types.Slice:
+				createStruct.Kind = "slice"
+			case *types.Array:
+				createStruct.Kind = "array"
+			case *types.Map:
+				createStruct.Kind = "map"
+			}
+		}
+	}
+
+	for _, elt := range lit.Elts {
+		eltAsKV, ok := asKeyValueExpr(elt)
+		if ok {
+			if nil == createStruct.KeyValueArgs {
+				createStruct.KeyValueArgs = make(map[string]string)
+			}
+			key := nodeText(fset, eltAsKV.Key)
+			createStruct.KeyValueArgs[key] = nodeText(fset, eltAsKV.Value)
+			createStruct.Fields = append(createStruct.Fields, FieldValue{
+				Key:   key,
+				Value: parseValue(eltAsKV.Value, fset, info, scope, fullPathToFile),
+				Code:  nodeText(fset, eltAsKV.Value),
+			})
+		} else {
+			createStruct.Args = append(createStruct.Args, nodeText(fset, elt))
+			createStruct.Fields = append(createStruct.Fields, FieldValue{
+				Value: parseValue(elt, fset, info, scope, fullPathToFile),
+				Code:  nodeText(fset, elt),
+			})
+		}
+	}
+	return createStruct
+}
+
+// parseValue parses an expression used as a value, unlike parseNode2 plain identifiers
+// and literals are always returned
+func parseValue(value ast.Expr, fset *token.FileSet, info *types.Info, scope Scope, fullPathToFile string) Expr {
+	switch v := value.(type) {
+	case *ast.Ident:
+		variable := Variable{
+			Type:     "variable",
+			CScope:   scope,
+			Name:     v.String(),
+			Offset:   v.Pos(),
+			Location: locationOf(fset, v),
+			Code:     v.String(),
+		}
+		if resolved, ok := resolveType(info, v); ok {
+			variable.Reference = resolved
+		}
+		return variable
+	case *ast.BasicLit:
+		return Value{
+			Type:     "constant",
+			CScope:   scope,
+			TypeOf:   v.Kind.String(),
+			Value:    v.Value,
+			Offset:   v.Pos(),
+			Location: locationOf(fset, v),
+			Code:     v.Value,
+		}
+	}
+	return parseNode2(value, fset, info, scope, fullPathToFile)
+}
+
+// declarationOf creates a Declaration spanning the node
+func declarationOf(fset *token.FileSet, node ast.Node, label string, kind string) Declaration {
+	return Declaration{
+		Label:     label,
+		Type:      kind,
+		Start:     fset.Position(node.Pos()).Offset,
+		End:       fset.Position(node.End()).Offset,
+		LineStart: fset.Position(node.Pos()).Line,
+		LineEnd:   fset.Position(node.End()).Line,
+		Children:  []Declaration{},
+	}
 }
 
 func outline(fset *token.FileSet, fileAst *ast.File) []Declaration {
@@ -391,16 +1083,15 @@ func outline(fset *token.FileSet, fileAst *ast.File) []Declaration {
 			if err != nil {
 				// reportError(fmt.Errorf("Failed to parse receiver type: %v", err))
 			}
-			declarations = append(declarations, Declaration{
-				decl.Name.String(),
-				"function",
-				receiverType,
-				decl.Pos(),
-				decl.End(),
-				fset.Position(decl.Pos()).Line,
-				fset.Position(decl.End()).Line,
-				[]Declaration{},
-			})
+			kind := "function"
+			if receiverType != "" {
+				kind = "method"
+			}
+			declaration := declarationOf(fset, decl, decl.Name.String(), kind)
+			declaration.ReceiverType = receiverType
+			declaration.Detail = nodeText(fset, decl.Type)
+			declaration.TypeParams = typeParams(fset, decl.Type.TypeParams)
+			declarations = append(declarations, declaration)
 		case *ast.GenDecl:
 			for _, spec := range decl.Specs {
 				switch spec := spec.(type) {
@@ -414,29 +1105,29 @@ func outline(fset *token.FileSet, fileAst *ast.File) []Declaration {
 				// 		[]Declaration{},
 				// 	})
 				case *ast.TypeSpec:
-					//TODO: Members if it's a struct or interface type?
-					declarations = append(declarations, Declaration{
-						spec.Name.String(),
-						"type",
-						"",
-						spec.Pos(),
-						spec.End(),
-						fset.Position(spec.Pos()).Line,
-						fset.Position(spec.End()).Line,
-						[]Declaration{},
-					})
+					declaration := declarationOf(fset, spec, spec.Name.String(), "type")
+					declaration.TypeParams = typeParams(fset, spec.TypeParams)
+					switch typ := spec.Type.(type) {
+					case *ast.StructType:
+						declaration.Detail = "struct"
+						declaration.Children = fieldDeclarations(fset, typ.Fields, "field")
+					case *ast.InterfaceType:
+						declaration.Detail = "interface"
+						declaration.Children = fieldDeclarations(fset, typ.Methods, "method")
+					default:
+						declaration.Detail = nodeText(fset, typ)
+					}
+					declarations = append(declarations, declaration)
 				case *ast.ValueSpec:
 					for _, id := range spec.Names {
-						declarations = append(declarations, Declaration{
-							id.Name,
-							"variable",
-							"",
-							id.Pos(),
-							id.End(),
-							fset.Position(id.Pos()).Line,
-							fset.Position(id.End()).Line,
-							[]Declaration{},
-						})
+						declaration := declarationOf(fset, id, id.Name, "variable")
+						if decl.Tok == token.CONST {
+							declaration.Type = "constant"
+						}
+						if nil != spec.Type {
+							declaration.Detail = nodeText(fset, spec.Type)
+						}
+						declarations = append(declarations, declaration)
 					}
 				default:
 					// reportError(fmt.Errorf("Unknown token type: %s", decl.Tok))
@@ -449,3 +1140,71 @@ func outline(fset *token.FileSet, fileAst *ast.File) []Declaration {
 
 	return declarations
 }
+
+// fieldDeclarations returns the struct fields or interface methods as declarations.
+// Embedded types don't have names, so they're labelled with the type itself.
+func fieldDeclarations(fset *token.FileSet, fields *ast.FieldList, kind string) []Declaration {
+	declarations := []Declaration{}
+	if nil == fields {
+		return declarations
+	}
+	for _, field := range fields.List {
+		detail := nodeText(fset, field.Type)
+		tag := ""
+		if nil != field.Tag {
+			tag = field.Tag.Value
+		}
+		if len(field.Names) == 0 {
+			declaration := declarationOf(fset, field, strings.TrimPrefix(detail, "*"), "embedded")
+			declaration.Detail = detail
+			declaration.Tag = tag
+			declarations = append(declarations, declaration)
+			continue
+		}
+		for _, name := range field.Names {
+			declaration := declarationOf(fset, field, name.String(), kind)
+			declaration.Detail = detail
+			declaration.Tag = tag
+			declarations = append(declarations, declaration)
+		}
+	}
+	return declarations
+}
+
+// typeParams returns the type parameters with their constraints, like "K comparable"
+func typeParams(fset *token.FileSet, fields *ast.FieldList) []string {
+	if nil == fields {
+		return nil
+	}
+	params := make([]string, 0)
+	for _, field := range fields.List {
+		constraint := nodeText(fset, field.Type)
+		for _, name := range field.Names {
+			params = append(params, name.String()+" "+constraint)
+		}
+	}
+	return params
+}
+
+// locationOf returns where the node is in its file. token.Pos is only meaningful within
+// our FileSet, so every expression carries the actual file, line, column and byte offset.
+func locationOf(fset *token.FileSet, node ast.Node) Location {
+	start := fset.Position(node.Pos())
+	end := fset.Position(node.End())
+	return Location{
+		File:       start.Filename,
+		Line:       start.Line,
+		Column:     start.Column,
+		ByteOffset: start.Offset,
+		EndLine:    end.Line,
+		EndColumn:  end.Column,
+		EndOffset:  end.Offset,
+	}
+}
+
+// nodeText returns the source code of the node
+func nodeText(fset *token.FileSet, node ast.Node) string {
+	buf := &bytes.Buffer{}
+	format.Node(buf, fset, node)
+	return buf.String()
+}
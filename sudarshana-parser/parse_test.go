@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+
+	"github.com/ashwanthkumar/devmerge_2k18/sudarshana-parser/schema"
+)
+
+// writeFiles writes the files of a package to a temporary directory
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	directory := t.TempDir()
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(directory, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return directory
+}
+
+func TestParsePackageWithEmptyReceiver(t *testing.T) {
+	const src = "package bad\n\nfunc () Foo() {}\n\nfunc ok() { println(\"x\") }\n"
+	directory := writeFiles(t, map[string]string{"a.go": src})
+
+	sources := parsePackage("bad", "bad", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+	if len(sources) != 1 {
+		t.Fatalf("expected 1 source file, got %d", len(sources))
+	}
+	found := false
+	for _, expr := range sources[0].Exprs {
+		if f, ok := expr.(Func); ok && f.Name == "println" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected the println call after the bad method, got %v", sources[0].Exprs)
+	}
+
+	fset := token.NewFileSet()
+	fileAst, _ := parser.ParseFile(fset, "a.go", src, 0)
+	if declarations := outline(fset, fileAst); len(declarations) != 2 {
+		t.Errorf("expected 2 declarations, got %v", declarations)
+	}
+}
+
+func TestStatementOfNestedCalls(t *testing.T) {
+	const src = `package stmt
+
+type X struct{}
+
+func (X) Do() error { return nil }
+
+func run(x X) {
+	if err := x.Do(); err != nil {
+		println(len("a"))
+	}
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("stmt", "stmt", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	contexts := make(map[string]string)
+	for _, source := range sources {
+		walkCalls(source.Exprs, func(call Func) {
+			contexts[call.Name] = call.Context
+		})
+	}
+	for name, context := range map[string]string{"Do": "if-init", "println": "if", "len": "if"} {
+		if contexts[name] != context {
+			t.Errorf("expected %s to be called in %q, got %q", name, context, contexts[name])
+		}
+	}
+}
+
+func TestIncDecIsWrite(t *testing.T) {
+	const src = `package counter
+
+type Counter struct{ Count int }
+
+func run(p *Counter) {
+	p.Count++
+	p.Count--
+	println(p.Count)
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("counter", "counter", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	accesses := make([]string, 0)
+	for _, expr := range sources[0].Exprs {
+		if property, ok := expr.(PropertyAccessInStruct); ok {
+			accesses = append(accesses, property.Access)
+		}
+	}
+	expected := []string{"write", "write"}
+	if len(accesses) != len(expected) || accesses[0] != "write" || accesses[1] != "write" {
+		t.Errorf("expected %v, got %v", expected, accesses)
+	}
+}
+
+func TestNamesOfCallsWithoutIdentifier(t *testing.T) {
+	const src = `package lits
+
+func run(s string, handlers []func()) {
+	go func() {}()
+	_ = []byte(s)
+	handlers[0]()
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("lits", "lits", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	names := make(map[string]bool)
+	for _, source := range sources {
+		walkCalls(source.Exprs, func(call Func) {
+			names[call.Name] = true
+		})
+	}
+	for _, name := range []string{"func", "[]byte", "handlers[0]"} {
+		if !names[name] {
+			t.Errorf("expected a call named %q, got %v", name, names)
+		}
+	}
+	if names["<nil>"] {
+		t.Errorf("expected no call named <nil>, got %v", names)
+	}
+}
+
+// everyExpr is a package with every kind of expression, nested in each other
+const everyExpr = `package all
+
+type Inner struct{ N int }
+
+type Options struct {
+	Name  string
+	Inner Inner
+}
+
+type Client struct{ Timeout int }
+
+func New(name string) *Client { return &Client{} }
+
+func (c *Client) Do(n int) int { return n }
+
+func run(x int) {
+	if y := New("a").Do(New("b").Timeout); y > x {
+		c := New("c")
+		c.Timeout = y
+		_ = &Options{Name: "a", Inner: Inner{N: c.Do(x)}}
+	}
+}
+`
+
+func TestParseOutputRoundTrip(t *testing.T) {
+	directory := writeFiles(t, map[string]string{"a.go": everyExpr, "doc.go": "package all\n"})
+	sources := parsePackage("example.com/all", "all", directory, nil, Meta{Source: "github.com"}, []string{"a.go", "doc.go"}, ParseOptions{})
+
+	var encoded bytes.Buffer
+	for _, source := range sources {
+		line, err := json.Marshal(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded.Write(append(line, '\n'))
+	}
+
+	var reencoded bytes.Buffer
+	decoder := schema.NewDecoder(bytes.NewReader(encoded.Bytes()))
+	for {
+		source, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		line, err := json.Marshal(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reencoded.Write(append(line, '\n'))
+	}
+	if encoded.String() != reencoded.String() {
+		t.Errorf("the output doesn't round trip:\n%s\n%s", encoded.String(), reencoded.String())
+	}
+}
+
+func TestParseOutputMatchesSchema(t *testing.T) {
+	sourcefile, err := jsonschema.Compile(filepath.Join("schema", "sourcefile.schema.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	directory := writeFiles(t, map[string]string{"a.go": everyExpr, "doc.go": "package all\n"})
+	sources := parsePackage("example.com/all", "all", directory, nil, Meta{Source: "github.com"}, []string{"a.go", "doc.go"}, ParseOptions{})
+
+	for _, source := range sources {
+		line, err := json.Marshal(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var record interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatal(err)
+		}
+		if err := sourcefile.Validate(record); err != nil {
+			t.Errorf("%s doesn't match the schema: %v", source.File, err)
+		}
+	}
+}
+
+func TestCalleeOfPromotedMethods(t *testing.T) {
+	const src = `package promoted
+
+import "sync"
+
+type S struct {
+	sync.Mutex
+	OnDone func()
+}
+
+func run(s *S) {
+	s.Lock()
+	s.OnDone()
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("example.com/promoted", "promoted", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	callees := make(map[string]string)
+	references := make(map[string]string)
+	for _, source := range sources {
+		walkCalls(source.Exprs, func(call Func) {
+			callees[call.Name] = call.Callee
+			references[call.Name] = call.Reference
+		})
+	}
+	if callees["Lock"] != "*sync.Mutex#Lock" {
+		t.Errorf("expected s.Lock() to call *sync.Mutex#Lock, got %q", callees["Lock"])
+	}
+	if references["Lock"] != "*example.com/promoted.S" {
+		t.Errorf("expected s.Lock() to be called on *example.com/promoted.S, got %q", references["Lock"])
+	}
+	if callees["OnDone"] != "" {
+		t.Errorf("expected s.OnDone() to have no callee, got %q", callees["OnDone"])
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/ashwanthkumar/devmerge_2k18/sudarshana-parser/schema"
+)
+
+// patternExamples is the number of concrete calls we keep for every template
+const patternExamples = 3
+
+// Pattern is a call template along with how often it's used and a few calls that use it
+type Pattern struct {
+	Reference string
+	Name      string
+	Template  string
+	Count     int
+	Examples  []string
+}
+
+// patternsMode reads the NDJSON output of the parser ("-" is stdin) and prints the call
+// templates in the popular_patterns.tsv format, most used first for every function, with the
+// count and a few representative calls as extra columns
+func patternsMode(input string) {
+	reader := io.Reader(os.Stdin)
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+
+	patterns, err := aggregatePatterns(schema.NewDecoder(reader))
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+
+	writer := csv.NewWriter(os.Stdout)
+	writer.Comma = '\t'
+	for _, pattern := range patterns {
+		// the web server expects the same number of columns in every row
+		examples := make([]string, patternExamples)
+		copy(examples, pattern.Examples)
+		row := []string{pattern.Reference, pattern.Name, pattern.Template, strconv.Itoa(pattern.Count)}
+		writer.Write(append(row, examples...))
+	}
+	writer.Flush()
+}
+
+// aggregatePatterns counts the calls by their template, the result is sorted by function and
+// then by the count
+func aggregatePatterns(decoder *schema.Decoder) ([]Pattern, error) {
+	type key struct {
+		reference, name, template string
+	}
+	index := make(map[key]int)
+	patterns := make([]Pattern, 0)
+	for {
+		source, err := decoder.Decode()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		walkCalls(source.Exprs, func(call Func) {
+			// a function literal called in place, a conversion or a builtin isn't an API anyone else calls
+			if call.Name == funcLitName || call.Conversion || call.Builtin {
+				return
+			}
+			site := toCallSite(*source, call)
+			if site.Template == "" {
+				return
+			}
+			k := key{site.Reference, site.Name, site.Template}
+			idx, present := index[k]
+			if !present {
+				idx = len(patterns)
+				index[k] = idx
+				patterns = append(patterns, Pattern{Reference: site.Reference, Name: site.Name, Template: site.Template})
+			}
+			pattern := &patterns[idx]
+			pattern.Count++
+			if len(pattern.Examples) < patternExamples && !containsString(pattern.Examples, site.Code) {
+				pattern.Examples = append(pattern.Examples, site.Code)
+			}
+		})
+	}
+
+	sort.SliceStable(patterns, func(i, j int) bool {
+		if patterns[i].Reference != patterns[j].Reference {
+			return patterns[i].Reference < patterns[j].Reference
+		}
+		if patterns[i].Name != patterns[j].Name {
+			return patterns[i].Name < patterns[j].Name
+		}
+		if patterns[i].Count != patterns[j].Count {
+			return patterns[i].Count > patterns[j].Count
+		}
+		return patterns[i].Template < patterns[j].Template
+	})
+	return patterns, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ashwanthkumar/devmerge_2k18/sudarshana-parser/schema"
+)
+
+func TestPatternsSkipConversionsAndBuiltins(t *testing.T) {
+	const src = `package kinds
+
+import "strings"
+
+type ID int
+
+func run(s string, n int, items []string) {
+	_ = []byte(s)
+	_ = ID(n)
+	_ = len(items)
+	items = append(items, s)
+	_ = strings.ToUpper(s)
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("example.com/kinds", "kinds", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	var encoded bytes.Buffer
+	for _, source := range sources {
+		line, err := json.Marshal(source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encoded.Write(append(line, '\n'))
+	}
+	patterns, err := aggregatePatterns(schema.NewDecoder(&encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(patterns) != 1 || patterns[0].Name != "ToUpper" {
+		t.Errorf("expected only the strings.ToUpper pattern, got %v", patterns)
+	}
+}
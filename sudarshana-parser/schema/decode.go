@@ -0,0 +1,238 @@
+package schema
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Values of the "type" field that tell the kinds of Expr apart
+const (
+	TypeFunction        = "function"
+	TypeVariable        = "variable"
+	TypeConstant        = "constant"
+	TypeAssignment      = "assignment"
+	TypeProperty        = "property"
+	TypeConstructStruct = "constructstruct"
+)
+
+// DecodeExpr decodes a single expression into its concrete type based on its "type" field.
+// A JSON null decodes to a nil Expr.
+func DecodeExpr(data []byte) (Expr, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+	var discriminator struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &discriminator); err != nil {
+		return nil, err
+	}
+	switch discriminator.Type {
+	case TypeFunction:
+		var expr Func
+		err := json.Unmarshal(data, &expr)
+		return expr, err
+	case TypeVariable:
+		var expr Variable
+		err := json.Unmarshal(data, &expr)
+		return expr, err
+	case TypeConstant:
+		var expr Value
+		err := json.Unmarshal(data, &expr)
+		return expr, err
+	case TypeAssignment:
+		var expr Assignment
+		err := json.Unmarshal(data, &expr)
+		return expr, err
+	case TypeProperty:
+		var expr PropertyAccessInStruct
+		err := json.Unmarshal(data, &expr)
+		return expr, err
+	case TypeConstructStruct:
+		var expr ConstructStruct
+		err := json.Unmarshal(data, &expr)
+		return expr, err
+	}
+	return nil, fmt.Errorf("unknown expression type %q", discriminator.Type)
+}
+
+func decodeExprs(raw []json.RawMessage) ([]Expr, error) {
+	if nil == raw {
+		return nil, nil
+	}
+	exprs := make([]Expr, 0, len(raw))
+	for _, data := range raw {
+		expr, err := DecodeExpr(data)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	return exprs, nil
+}
+
+func isNull(data []byte) bool {
+	trimmed := strings.TrimSpace(string(data))
+	return trimmed == "" || trimmed == "null"
+}
+
+// UnmarshalJSON decodes the polymorphic lines of the file
+func (v *SourceFile) UnmarshalJSON(data []byte) error {
+	type plain SourceFile
+	var raw struct {
+		plain
+		Exprs []json.RawMessage `json:"lines"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	exprs, err := decodeExprs(raw.Exprs)
+	if err != nil {
+		return err
+	}
+	*v = SourceFile(raw.plain)
+	v.Exprs = exprs
+	return nil
+}
+
+// UnmarshalJSON decodes the receiver and the arguments of the call
+func (v *Func) UnmarshalJSON(data []byte) error {
+	type plain Func
+	var raw struct {
+		plain
+		Receiver json.RawMessage   `json:"receiver"`
+		Args     []json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	receiver, err := DecodeExpr(raw.Receiver)
+	if err != nil {
+		return err
+	}
+	args, err := decodeExprs(raw.Args)
+	if err != nil {
+		return err
+	}
+	*v = Func(raw.plain)
+	v.Receiver = receiver
+	v.Args = args
+	return nil
+}
+
+// UnmarshalJSON decodes both sides of the assignment
+func (v *Assignment) UnmarshalJSON(data []byte) error {
+	type plain Assignment
+	var raw struct {
+		plain
+		Lefts  []json.RawMessage `json:"lhs"`
+		Rights []json.RawMessage `json:"rhsList"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	lefts, err := decodeExprs(raw.Lefts)
+	if err != nil {
+		return err
+	}
+	rights, err := decodeExprs(raw.Rights)
+	if err != nil {
+		return err
+	}
+	*v = Assignment(raw.plain)
+	v.Lefts = lefts
+	v.Rights = rights
+	return nil
+}
+
+// UnmarshalJSON decodes the receiver the field is selected from
+func (v *PropertyAccessInStruct) UnmarshalJSON(data []byte) error {
+	type plain PropertyAccessInStruct
+	var raw struct {
+		plain
+		Receiver json.RawMessage `json:"receiver"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	receiver, err := DecodeExpr(raw.Receiver)
+	if err != nil {
+		return err
+	}
+	*v = PropertyAccessInStruct(raw.plain)
+	v.Receiver = receiver
+	return nil
+}
+
+// UnmarshalJSON decodes the value of the element
+func (v *FieldValue) UnmarshalJSON(data []byte) error {
+	type plain FieldValue
+	var raw struct {
+		plain
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	value, err := DecodeExpr(raw.Value)
+	if err != nil {
+		return err
+	}
+	*v = FieldValue(raw.plain)
+	v.Value = value
+	return nil
+}
+
+// Decoder reads a stream of SourceFile records, one JSON object per line
+type Decoder struct {
+	reader *bufio.Reader
+	line   int
+}
+
+// NewDecoder returns a Decoder reading the NDJSON stream from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{reader: bufio.NewReader(r)}
+}
+
+// Decode returns the next SourceFile in the stream, or io.EOF at the end of it. Records
+// written with a different major version of the schema are rejected.
+func (d *Decoder) Decode() (*SourceFile, error) {
+	for {
+		line, err := d.reader.ReadBytes('\n')
+		if len(line) == 0 && err != nil {
+			return nil, err
+		}
+		d.line++
+		if isNull(line) {
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var source SourceFile
+		if jsonErr := json.Unmarshal(line, &source); jsonErr != nil {
+			return nil, fmt.Errorf("line %d: %v", d.line, jsonErr)
+		}
+		if !Compatible(source.SchemaVersion) {
+			return nil, fmt.Errorf("line %d: schema version %q is not compatible with %q", d.line, source.SchemaVersion, Version)
+		}
+		return &source, nil
+	}
+}
+
+// Compatible tells if records written with the version can be decoded, i.e. it has the same
+// major version as ours
+func Compatible(version string) bool {
+	return majorVersion(version) == majorVersion(Version)
+}
+
+func majorVersion(version string) string {
+	if idx := strings.Index(version, "."); idx >= 0 {
+		return version[:idx]
+	}
+	return version
+}
@@ -0,0 +1,137 @@
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sample has every kind of Expr, nested in a receiver, an argument, the sides of an
+// assignment and the fields of a literal
+func sample() SourceFile {
+	scope := Scope{Package: "example.com/a", Function: "run", Receiver: "*T"}
+	statement := Statement{Context: "if-init", Depth: 1}
+	location := Location{File: "/src/a/a.go", Line: 3, Column: 2, ByteOffset: 40, EndLine: 3, EndColumn: 9, EndOffset: 47}
+	constant := Value{TypeOf: "STRING", Value: `"a"`, Location: location, CScope: scope, Type: TypeConstant, Code: `"a"`, Statement: statement}
+	variable := Variable{Name: "x", Reference: "int", Location: location, CScope: scope, Type: TypeVariable, Code: "x", Statement: statement}
+	newCall := Func{
+		Name:      "New",
+		Reference: "example.com/b",
+		Callee:    "example.com/b#New",
+		Args:      []Expr{constant},
+		ArgTypes:  []Argument{{Index: 0, Kind: "literal", Type: "string", Param: "name", ParamType: "string", Code: `"a"`}},
+		Template:  "b.New(${string})",
+		Location:  location,
+		CScope:    scope,
+		Type:      TypeFunction,
+		Code:      `b.New("a")`,
+		Statement: statement,
+	}
+	property := PropertyAccessInStruct{
+		Struct:    "*example.com/b.Client",
+		Property:  "Timeout",
+		FieldType: "int",
+		Access:    "read",
+		Receiver:  newCall,
+		Location:  location,
+		CScope:    scope,
+		Type:      TypeProperty,
+		Code:      `b.New("a").Timeout`,
+		Statement: statement,
+	}
+	doCall := Func{
+		Name:             "Do",
+		Reference:        "*example.com/b.Client",
+		Callee:           "*example.com/b.Client#Do",
+		Receiver:         newCall,
+		TypeArgs:         []string{"int"},
+		ExplicitTypeArgs: true,
+		Args:             []Expr{property, variable},
+		Location:         location,
+		CScope:           scope,
+		Type:             TypeFunction,
+		Code:             `b.New("a").Do[int](b.New("a").Timeout, x)`,
+		Statement:        statement,
+	}
+	literal := ConstructStruct{
+		Struct:    "b.Options",
+		Reference: "example.com/b.Options",
+		Kind:      "struct",
+		Pointer:   true,
+		Fields: []FieldValue{
+			{Key: "Name", Value: constant, Code: `Name: "a"`},
+			{Key: "Inner", Value: ConstructStruct{Struct: "b.Inner", Kind: "struct", Fields: []FieldValue{{Key: "Call", Value: doCall, Code: "Call: ..."}}, CScope: scope, Type: TypeConstructStruct, Location: location, Code: "b.Inner{...}"}, Code: "Inner: b.Inner{...}"},
+			{Value: variable, Code: "x"},
+		},
+		CScope:    scope,
+		Type:      TypeConstructStruct,
+		Location:  location,
+		Code:      "&b.Options{...}",
+		Statement: statement,
+	}
+	assignment := Assignment{
+		Lefts:     []Expr{variable, PropertyAccessInStruct{Struct: "T", Property: "F", Access: "write", Location: location, CScope: scope, Type: TypeProperty, Code: "t.F"}},
+		Rights:    []Expr{doCall, literal},
+		Pairs:     []AssignmentPair{{Left: 0, Right: 0}, {Left: 1, Right: 1}},
+		Operator:  "=",
+		Location:  location,
+		CScope:    scope,
+		Type:      TypeAssignment,
+		Code:      "x, t.F = ...",
+		Statement: statement,
+	}
+	return SourceFile{
+		SchemaVersion: Version,
+		Meta:          Meta{Source: "github.com", Repo: "https://github.com/example/a", License: "MIT", Stars: 3},
+		Path:          "/src/a/a.go",
+		Package:       "a",
+		File:          "a.go",
+		Module:        &Module{Path: "example.com/a", Version: "v1.0.0"},
+		Exprs:         []Expr{assignment, doCall, property, literal, constant, variable},
+	}
+}
+
+func TestDecodeRoundTrip(t *testing.T) {
+	empty := SourceFile{SchemaVersion: Version, Meta: Meta{Source: "github.com"}, Path: "/src/a/doc.go", Package: "a", File: "doc.go"}
+	records := []SourceFile{sample(), empty}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.Contains(buf.String(), `"lines":null`) {
+		t.Errorf("expected a file without expressions to have null lines, got %s", buf.String())
+	}
+
+	decoder := NewDecoder(&buf)
+	for idx, expected := range records {
+		decoded, err := decoder.Decode()
+		if err != nil {
+			t.Fatalf("record %d: %v", idx, err)
+		}
+		if !reflect.DeepEqual(*decoded, expected) {
+			t.Errorf("record %d doesn't round trip:\nexpected %#v\ngot      %#v", idx, expected, *decoded)
+		}
+	}
+	if _, err := decoder.Decode(); err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the stream, got %v", err)
+	}
+}
+
+func TestDecodeRejectsOtherMajorVersions(t *testing.T) {
+	record := sample()
+	record.SchemaVersion = "0.9.0"
+	line, err := json.Marshal(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewDecoder(bytes.NewReader(line)).Decode(); err == nil {
+		t.Errorf("expected schema version 0.9.0 to be rejected")
+	}
+}
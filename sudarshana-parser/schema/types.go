@@ -0,0 +1,419 @@
+// Package schema has the records the parser emits, one SourceFile per line of JSON, and
+// decodes such a stream back into typed values.
+package schema
+
+import (
+	"fmt"
+	"go/token"
+)
+
+// Version of the schema, the major version changes when a field is removed or changes its meaning
+// and the minor version when fields are added or filled for more expressions. The cache is keyed
+// on it, so any change to what the parser emits bumps it.
+const Version = "2.4.0"
+
+// GetAllPositions returns the positions of all the expressions and their sub-expressions
+func GetAllPositions(exprs []Expr) []token.Pos {
+	positions := make([]token.Pos, 0)
+	for _, expr := range exprs {
+		// fmt.Printf("%v\n", expr)
+		positions = append(positions, expr.AllPos()...)
+	}
+	return positions
+}
+
+// Meta represents the metadata for the SourceFile
+type Meta struct {
+	// Source is the host of the repository, github.com when we can't tell
+	Source string `json:"source"`
+	// Repo is the URL of the repository, from the origin remote or the module path
+	Repo string `json:"repo,omitempty"`
+	// Commit and CommitDate (RFC 3339) are of the HEAD of the working copy
+	Commit     string `json:"commit,omitempty"`
+	CommitDate string `json:"commitDate,omitempty"`
+	// Module and GoVersion are declared in the nearest go.mod
+	Module    string `json:"module,omitempty"`
+	GoVersion string `json:"goVersion,omitempty"`
+	// License is the SPDX identifier of the license file, or "other"
+	License string `json:"license,omitempty"`
+	// Stars and Forks come from the manifest given to the parser
+	Stars int `json:"stars,omitempty"`
+	Forks int `json:"forks,omitempty"`
+}
+
+// SourceFile represents the parsed AST for the given file
+type SourceFile struct {
+	// SchemaVersion is the Version of the schema the record was written with
+	SchemaVersion string `json:"schemaVersion"`
+	Meta          Meta   `json:"meta"`
+	Path          string `json:"path"`
+	Package       string `json:"package"`
+	File          string `json:"file"`
+	// Module is the module (and version) the file came from, if it was loaded in module mode
+	Module *Module `json:"module,omitempty"`
+	// Constraint is the //go:build expression of the file
+	Constraint string `json:"constraint,omitempty"`
+	// Targets are the GOOS/GOARCH platforms the file is built for, out of the ones the parser was
+	// asked about (it accounts for _linux.go style names as well). It's empty when every file is parsed.
+	Targets []string `json:"targets,omitempty"`
+	Exprs   []Expr   `json:"lines"`
+	// Test is set for _test.go files
+	Test bool `json:"test,omitempty"`
+	// Examples has the Example functions of a test file
+	Examples []Example `json:"examples,omitempty"`
+	// Errors has the problems we ran into while reading or parsing the file, Exprs has whatever could still be recovered
+	Errors []ParseError `json:"errors,omitempty"`
+}
+
+// Module records where a parsed file came from
+type Module struct {
+	Path    string `json:"path"`
+	Version string `json:"version,omitempty"`
+	// Replace is the replacement of the module (path@version or a local directory) from a replace directive
+	Replace string `json:"replace,omitempty"`
+}
+
+// Example is an Example function from a test file along with its expected output. They're
+// the best usage patterns a library ships, so they carry a higher weight than mined calls.
+type Example struct {
+	// Name is the name of the example function, like ExampleClient_Do
+	Name string `json:"name"`
+	// Reference and Func are the documented identifier, Client_Do in net/http is
+	// *net/http.Client and Do, ExampleGet is net/http and Get and ExampleClient is
+	// net/http.Client without a Func
+	Reference string `json:"reference"`
+	Func      string `json:"func,omitempty"`
+	Code      string `json:"code"`
+	Output    string `json:"output,omitempty"`
+	Unordered bool   `json:"unordered,omitempty"`
+	Weight    int    `json:"weight"`
+}
+
+// ParseError represents a file that couldn't be read, a syntax error or a failure while walking the AST
+type ParseError struct {
+	// Kind is one of "read", "syntax" or "internal"
+	Kind    string `json:"kind"`
+	File    string `json:"file"`
+	Line    int    `json:"line,omitempty"`
+	Column  int    `json:"column,omitempty"`
+	Offset  int    `json:"offset,omitempty"`
+	Message string `json:"message"`
+}
+
+func (e ParseError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.File, e.Message)
+}
+
+// Location is the span of an expression in its file
+type Location struct {
+	File       string `json:"file"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+	ByteOffset int    `json:"offset"`
+	EndLine    int    `json:"endLine"`
+	EndColumn  int    `json:"endColumn"`
+	EndOffset  int    `json:"endOffset"`
+}
+
+// Scope is where an expression is - the package, and the function (with its receiver) or
+// the function literal inside it.
+type Scope struct {
+	Package  string `json:"package"`
+	Receiver string `json:"receiver,omitempty"`
+	Function string `json:"function,omitempty"`
+	// Closure is the path of function literals inside the function, named like the go compiler
+	// does - func1 for the first literal in the function and func1.2 for the second one inside it
+	Closure string `json:"closure,omitempty"`
+	// Tag is test, benchmark, example or fuzz for functions in test files (helpers are tagged test)
+	Tag string `json:"tag,omitempty"`
+	// Location spans the function or the function literal
+	Location *Location `json:"location,omitempty"`
+}
+
+// Name returns the scope as Recv#Function.func1
+func (s Scope) Name() string {
+	name := s.Function
+	if s.Receiver != "" {
+		name = s.Receiver + "#" + name
+	}
+	if s.Closure != "" {
+		name = name + "." + s.Closure
+	}
+	return name
+}
+
+// Statement describes the statement an expression is extracted from
+type Statement struct {
+	// Context is the kind of the nearest enclosing statement: defer, go, return, if-init,
+	// if-cond, if, for, range, select-case or switch-case. It's empty for plain statements.
+	Context string `json:"context,omitempty"`
+	// Depth is the number of blocks the expression is nested in within its function
+	Depth int `json:"depth"`
+}
+
+// Base type of all Expressions, the Type of each of them tells them apart in JSON
+type Expr interface {
+	Scope() Scope
+	Pos() token.Pos
+	AllPos() []token.Pos
+}
+
+// Func represents a function call
+type Func struct {
+	Name string `json:"name"`
+	// Reference has a non-empty value, if this instance is invoked from a package or a struct.
+	// It's the import path of the package or the fully-qualified receiver type when the type checker could resolve it.
+	Reference string `json:"reference,omitempty"`
+	// Callee is the fully-qualified name of the function, e.g. *github.com/gin-gonic/gin.Context#JSON
+	// for methods and github.com/gin-gonic/gin#New for functions. Promoted methods are named after
+	// the type declaring them, and calls of a func held in a field have none.
+	Callee string `json:"callee,omitempty"`
+	// Receiver is the call or field access this method is invoked on, when it's not a plain identifier
+	Receiver Expr `json:"receiver,omitempty"`
+	// TypeArgs are the type arguments of a call to a generic function, as written when
+	// ExplicitTypeArgs is set and as inferred by the type checker otherwise
+	TypeArgs         []string `json:"typeArgs,omitempty"`
+	ExplicitTypeArgs bool     `json:"explicitTypeArgs,omitempty"`
+	// Conversion is set for type conversions like []byte(s) and Builtin for calls of the
+	// builtins like len and append, neither calls a function
+	Conversion bool   `json:"conversion,omitempty"`
+	Builtin    bool   `json:"builtin,omitempty"`
+	Args       []Expr `json:"arguments"`
+	// ArgTypes describes every argument as written, Args skips the ones we don't extract
+	ArgTypes []Argument `json:"argTypes,omitempty"`
+	// Template is the call with the local identifiers replaced by ${type} and the literals by
+	// ${string}, ${int}, ${float}, ${rune} or ${imag}, calls with the same Template are the same pattern
+	Template string    `json:"template,omitempty"`
+	Offset   token.Pos `json:"-"`
+	Location
+	CScope Scope  `json:"scope"`
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+	Statement
+}
+
+func (v Func) Pos() token.Pos {
+	return v.Offset
+}
+
+func (v Func) Scope() Scope {
+	return v.CScope
+}
+
+func (v Func) AllPos() []token.Pos {
+	positions := make([]token.Pos, 0)
+	positions = append(positions, v.Pos())
+	if nil != v.Receiver {
+		positions = append(positions, v.Receiver.AllPos()...)
+	}
+	positions = append(positions, GetAllPositions(v.Args)...)
+	return positions
+}
+
+// Argument is an argument of a function call
+type Argument struct {
+	Index int `json:"index"`
+	// Kind is one of ident, literal, call, selector, funclit, composite or other
+	Kind string `json:"kind"`
+	// Type is the fully-qualified static type, when the type checker knows it
+	Type string `json:"type,omitempty"`
+	// Param is the name of the parameter of the callee the argument is passed as, and ParamType
+	// its type. A single multi-value argument, f(g()), is passed as all of them.
+	Param     string `json:"param,omitempty"`
+	ParamType string `json:"paramType,omitempty"`
+	// Variadic is set for the arguments passed as a single value of a ...T parameter
+	Variadic bool   `json:"variadic,omitempty"`
+	Code     string `json:"code"`
+}
+
+// Variable represents a variable access in an expression
+type Variable struct {
+	Name string `json:"name"`
+	// Reference has a non-empty value, if this instance is invoked from a package or a struct
+	Reference string `json:"reference,omitempty"`
+	// Blank is set for the _ identifier on the left hand side of an assignment
+	Blank  bool      `json:"blank,omitempty"`
+	Offset token.Pos `json:"-"`
+	Location
+	CScope Scope  `json:"scope"`
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+	Statement
+}
+
+func (v Variable) Pos() token.Pos {
+	return v.Offset
+}
+
+func (v Variable) Scope() Scope {
+	return v.CScope
+}
+
+func (v Variable) AllPos() []token.Pos {
+	positions := make([]token.Pos, 0)
+	positions = append(positions, v.Pos())
+	return positions
+}
+
+// Value represents a constanct of type string, int, double etc.
+type Value struct {
+	TypeOf string    `json:"typeOf"`
+	Value  string    `json:"value"`
+	Offset token.Pos `json:"-"`
+	Location
+	CScope Scope  `json:"scope"`
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+	Statement
+}
+
+func (v Value) Pos() token.Pos {
+	return v.Offset
+}
+
+func (v Value) Scope() Scope {
+	return v.CScope
+}
+
+func (v Value) AllPos() []token.Pos {
+	positions := make([]token.Pos, 0)
+	positions = append(positions, v.Pos())
+	return positions
+}
+
+// Assignment represents an assignment expression
+type Assignment struct {
+	Lefts  []Expr `json:"lhs"`
+	Rights []Expr `json:"rhsList,omitempty"`
+	// Pairs tells which of the Rights is assigned to each of the Lefts
+	Pairs []AssignmentPair `json:"pairs,omitempty"`
+	// Operator is the assignment token (:=, =, +=, ...) or var / const for declarations
+	Operator string    `json:"operator"`
+	Offset   token.Pos `json:"-"`
+	Location
+	CScope Scope  `json:"scope"`
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+	Statement
+}
+
+func (v Assignment) Pos() token.Pos {
+	return v.Offset
+}
+
+func (v Assignment) Scope() Scope {
+	return v.CScope
+}
+
+func (v Assignment) AllPos() []token.Pos {
+	positions := make([]token.Pos, 0)
+	positions = append(positions, GetAllPositions(v.Lefts)...)
+	for _, right := range v.Rights {
+		if nil != right {
+			positions = append(positions, right.AllPos()...)
+		}
+	}
+	return positions
+}
+
+// AssignmentPair links the left hand side at index Left to the right hand side at index Right
+// (-1 when there's no value). Result is the index of the result for multi-value right hand
+// sides like a, b := f()
+type AssignmentPair struct {
+	Left   int `json:"lhs"`
+	Right  int `json:"rhs"`
+	Result int `json:"result"`
+}
+
+// PropertyAccessInStruct represents reading or writing a field of a struct
+type PropertyAccessInStruct struct {
+	// Struct is the fully-qualified type the field is accessed on
+	Struct    string `json:"struct"`
+	Property  string `json:"property"`
+	FieldType string `json:"fieldType,omitempty"`
+	// Access is either "read" or "write"
+	Access string `json:"access"`
+	// Receiver is the call or field access the field is selected from, when it's not a plain identifier
+	Receiver Expr      `json:"receiver,omitempty"`
+	Offset   token.Pos `json:"-"`
+	Location
+	CScope Scope  `json:"scope"`
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+	Statement
+}
+
+func (v PropertyAccessInStruct) Pos() token.Pos {
+	return v.Offset
+}
+
+func (v PropertyAccessInStruct) Scope() Scope {
+	return v.CScope
+}
+
+func (v PropertyAccessInStruct) AllPos() []token.Pos {
+	positions := make([]token.Pos, 0)
+	positions = append(positions, v.Pos())
+	if nil != v.Receiver {
+		positions = append(positions, v.Receiver.AllPos()...)
+	}
+	return positions
+}
+
+// ConstructStruct represents a composite literal - a struct, slice, array or map
+type ConstructStruct struct {
+	// Struct is the type as written, or the resolved type for elided literals like the elements of []T{{...}}
+	Struct string `json:"struct"`
+	// Reference is the fully-qualified type of the literal (without type arguments), when the type checker could resolve it
+	Reference string `json:"reference,omitempty"`
+	// TypeArgs are the type arguments of an instantiated generic type like List[int]
+	TypeArgs []string `json:"typeArgs,omitempty"`
+	// Kind is one of struct, slice, array or map
+	Kind string `json:"kind,omitempty"`
+	// Pointer is set for &T{...}
+	Pointer      bool              `json:"pointer,omitempty"`
+	Args         []string          `json:"arguments,omitempty"`
+	KeyValueArgs map[string]string `json:"kvargs,omitempty"`
+	// Fields has the parsed value of every element (with its key, if any) in the order they're written
+	Fields []FieldValue `json:"fields,omitempty"`
+	CScope Scope        `json:"scope"`
+	Type   string       `json:"type"`
+	Offset token.Pos    `json:"-"`
+	Location
+	Code string `json:"code"`
+	Statement
+	// LiteralPos is the position of T{...} inside &T{...}, so the walker doesn't pick the literal up again
+	LiteralPos token.Pos `json:"-"`
+}
+
+// FieldValue is an element of a composite literal
+type FieldValue struct {
+	Key   string `json:"key,omitempty"`
+	Value Expr   `json:"value,omitempty"`
+	Code  string `json:"code"`
+}
+
+func (v ConstructStruct) Pos() token.Pos {
+	return v.Offset
+}
+
+func (v ConstructStruct) Scope() Scope {
+	return v.CScope
+}
+
+func (v ConstructStruct) AllPos() []token.Pos {
+	positions := make([]token.Pos, 0)
+	positions = append(positions, v.Pos())
+	if v.LiteralPos.IsValid() {
+		positions = append(positions, v.LiteralPos)
+	}
+	for _, field := range v.Fields {
+		if nil != field.Value {
+			positions = append(positions, field.Value.AllPos()...)
+		}
+	}
+	return positions
+}
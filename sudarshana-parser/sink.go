@@ -0,0 +1,341 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// Sink receives the parsed files, in the order they're parsed
+type Sink interface {
+	Write(source SourceFile) error
+	// Close flushes whatever is buffered, the output is incomplete until it's called
+	Close() error
+}
+
+// SinkOptions selects the format and the destination of the parsed files
+type SinkOptions struct {
+	// Format is one of ndjson, csv, tsv or parquet
+	Format string
+	// Out is the file to write to, or the directory of the shards when ShardSize is set. An
+	// empty Out or "-" writes to stdout.
+	Out string
+	// ShardSize is the number of files written to each shard, part-00000.ndjson, part-00001.ndjson and so on
+	ShardSize int
+	// Gzip compresses the output, for parquet it's the compression of the column chunks
+	Gzip bool
+}
+
+var sinkExtensions = map[string]string{
+	"ndjson":  ".ndjson",
+	"csv":     ".csv",
+	"tsv":     ".tsv",
+	"parquet": ".parquet",
+}
+
+// newSink returns the sink described by the options
+func newSink(options SinkOptions) (Sink, error) {
+	if _, present := sinkExtensions[options.Format]; !present {
+		return nil, fmt.Errorf("unknown output format %q, expected one of ndjson, csv, tsv or parquet", options.Format)
+	}
+	if options.ShardSize > 0 {
+		if options.Out == "" || options.Out == "-" {
+			return nil, fmt.Errorf("sharded output needs a directory to write to")
+		}
+		if err := os.MkdirAll(options.Out, 0755); err != nil {
+			return nil, err
+		}
+		return &shardedSink{options: options}, nil
+	}
+	if options.Out == "" || options.Out == "-" {
+		return openSink(options, nopCloser{os.Stdout})
+	}
+	file, err := os.Create(options.Out)
+	if err != nil {
+		return nil, err
+	}
+	return openSink(options, file)
+}
+
+// openSink writes the format to the output, which is closed along with the sink
+func openSink(options SinkOptions, output io.WriteCloser) (Sink, error) {
+	closers := []io.Closer{output}
+	writer := io.Writer(output)
+	if options.Gzip && options.Format != "parquet" {
+		compressed := gzip.NewWriter(output)
+		closers = append([]io.Closer{compressed}, closers...)
+		writer = compressed
+	}
+
+	var sink Sink
+	switch options.Format {
+	case "ndjson":
+		sink = &ndjsonSink{encoder: json.NewEncoder(writer)}
+	case "csv":
+		sink = &csvSink{writer: csv.NewWriter(writer)}
+	case "tsv":
+		csvWriter := csv.NewWriter(writer)
+		csvWriter.Comma = '\t'
+		sink = &csvSink{writer: csvWriter}
+	case "parquet":
+		var writerOptions []parquet.WriterOption
+		if options.Gzip {
+			writerOptions = append(writerOptions, parquet.Compression(&parquet.Gzip))
+		}
+		sink = &parquetSink{writer: parquet.NewGenericWriter[CallSite](writer, writerOptions...)}
+	}
+	return &closingSink{Sink: sink, closers: closers}, nil
+}
+
+// closingSink closes the compression and the file underneath the sink, in that order
+type closingSink struct {
+	Sink
+	closers []io.Closer
+}
+
+func (s *closingSink) Close() error {
+	err := s.Sink.Close()
+	for _, closer := range s.closers {
+		if closeErr := closer.Close(); closeErr != nil && nil == err {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// shardedSink starts a new file in the directory every ShardSize files
+type shardedSink struct {
+	options SinkOptions
+	shard   int
+	written int
+	current Sink
+}
+
+func (s *shardedSink) Write(source SourceFile) error {
+	if s.current != nil && s.written == s.options.ShardSize {
+		if err := s.current.Close(); err != nil {
+			return err
+		}
+		s.current = nil
+		s.shard++
+	}
+	if nil == s.current {
+		name := fmt.Sprintf("part-%05d%s", s.shard, sinkExtensions[s.options.Format])
+		if s.options.Gzip && s.options.Format != "parquet" {
+			name += ".gz"
+		}
+		file, err := os.Create(filepath.Join(s.options.Out, name))
+		if err != nil {
+			return err
+		}
+		s.current, err = openSink(s.options, file)
+		if err != nil {
+			return err
+		}
+		s.written = 0
+	}
+	s.written++
+	return s.current.Write(source)
+}
+
+func (s *shardedSink) Close() error {
+	if nil == s.current {
+		return nil
+	}
+	return s.current.Close()
+}
+
+// ndjsonSink writes every SourceFile as a single line of JSON
+type ndjsonSink struct {
+	encoder *json.Encoder
+}
+
+func (s *ndjsonSink) Write(source SourceFile) error {
+	return s.encoder.Encode(source)
+}
+
+func (s *ndjsonSink) Close() error {
+	return nil
+}
+
+// csvSink writes a row for every call site, with a header row at the top
+type csvSink struct {
+	writer      *csv.Writer
+	wroteHeader bool
+}
+
+func (s *csvSink) Write(source SourceFile) error {
+	if !s.wroteHeader {
+		if err := s.writer.Write(callSiteColumns); err != nil {
+			return err
+		}
+		s.wroteHeader = true
+	}
+	for _, site := range callSites(source) {
+		if err := s.writer.Write(site.record()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *csvSink) Close() error {
+	s.writer.Flush()
+	return s.writer.Error()
+}
+
+// parquetSink writes a row for every call site
+type parquetSink struct {
+	writer *parquet.GenericWriter[CallSite]
+}
+
+func (s *parquetSink) Write(source SourceFile) error {
+	_, err := s.writer.Write(callSites(source))
+	return err
+}
+
+func (s *parquetSink) Close() error {
+	return s.writer.Close()
+}
+
+// CallSite is a function call flattened into a single row for the csv, tsv and parquet sinks
+type CallSite struct {
+	Path          string `parquet:"path"`
+	Package       string `parquet:"package"`
+	File          string `parquet:"file"`
+	Module        string `parquet:"module"`
+	ModuleVersion string `parquet:"module_version"`
+	Line          int    `parquet:"line"`
+	Column        int    `parquet:"column"`
+	Offset        int    `parquet:"offset"`
+	EndLine       int    `parquet:"end_line"`
+	EndColumn     int    `parquet:"end_column"`
+	EndOffset     int    `parquet:"end_offset"`
+	// Scope is the enclosing function as Recv#Function.func1
+	Scope     string `parquet:"scope"`
+	Tag       string `parquet:"tag"`
+	Context   string `parquet:"context"`
+	Depth     int    `parquet:"depth"`
+	Reference string `parquet:"reference"`
+	Name      string `parquet:"name"`
+	Callee    string `parquet:"callee"`
+	// TypeArgs are comma separated
+	TypeArgs string `parquet:"type_args"`
+	// Arguments is the number of arguments as written, Args only has the ones that are mined
+	Arguments int    `parquet:"arguments"`
+	Code      string `parquet:"code"`
+	Template  string `parquet:"template"`
+}
+
+// callSiteColumns is the header of the csv and tsv output, in the order of record()
+var callSiteColumns = []string{
+	"path", "package", "file", "module", "module_version",
+	"line", "column", "offset", "end_line", "end_column", "end_offset",
+	"scope", "tag", "context", "depth",
+	"reference", "name", "callee", "type_args", "arguments", "code", "template",
+}
+
+func (c CallSite) record() []string {
+	return []string{
+		c.Path, c.Package, c.File, c.Module, c.ModuleVersion,
+		strconv.Itoa(c.Line), strconv.Itoa(c.Column), strconv.Itoa(c.Offset),
+		strconv.Itoa(c.EndLine), strconv.Itoa(c.EndColumn), strconv.Itoa(c.EndOffset),
+		c.Scope, c.Tag, c.Context, strconv.Itoa(c.Depth),
+		c.Reference, c.Name, c.Callee, c.TypeArgs, strconv.Itoa(c.Arguments), c.Code, c.Template,
+	}
+}
+
+// callSites returns every function call in the file as a row
+func callSites(source SourceFile) []CallSite {
+	sites := make([]CallSite, 0)
+	walkCalls(source.Exprs, func(call Func) {
+		sites = append(sites, toCallSite(source, call))
+	})
+	return sites
+}
+
+// walkCalls visits every function call in the expressions, including the ones nested in the
+// arguments, receivers and assignments of other expressions
+func walkCalls(exprs []Expr, visit func(call Func)) {
+	var collect func(expr Expr)
+	collect = func(expr Expr) {
+		switch e := expr.(type) {
+		case Func:
+			visit(e)
+			if nil != e.Receiver {
+				collect(e.Receiver)
+			}
+			for _, arg := range e.Args {
+				collect(arg)
+			}
+		case Assignment:
+			for _, left := range e.Lefts {
+				collect(left)
+			}
+			for _, right := range e.Rights {
+				if nil != right {
+					collect(right)
+				}
+			}
+		case PropertyAccessInStruct:
+			if nil != e.Receiver {
+				collect(e.Receiver)
+			}
+		case ConstructStruct:
+			for _, field := range e.Fields {
+				if nil != field.Value {
+					collect(field.Value)
+				}
+			}
+		}
+	}
+	for _, expr := range exprs {
+		collect(expr)
+	}
+}
+
+func toCallSite(source SourceFile, call Func) CallSite {
+	site := CallSite{
+		Path:      source.Path,
+		Package:   source.Package,
+		File:      source.File,
+		Line:      call.Line,
+		Column:    call.Column,
+		Offset:    call.ByteOffset,
+		EndLine:   call.EndLine,
+		EndColumn: call.EndColumn,
+		EndOffset: call.EndOffset,
+		Scope:     call.CScope.Name(),
+		Tag:       call.CScope.Tag,
+		Context:   call.Context,
+		Depth:     call.Depth,
+		Reference: call.Reference,
+		Name:      call.Name,
+		Callee:    call.Callee,
+		TypeArgs:  strings.Join(call.TypeArgs, ","),
+		Arguments: len(call.ArgTypes),
+		Code:      call.Code,
+		Template:  call.Template,
+	}
+	if source.Module != nil {
+		site.Module = source.Module.Path
+		site.ModuleVersion = source.Module.Version
+	}
+	return site
+}
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCallSiteArity(t *testing.T) {
+	const src = `package arity
+
+func f(a, b int) {}
+
+func run(a, b int, x []int, i int) {
+	f(a+b, x[i])
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("example.com/arity", "arity", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	sites := callSites(sources[0])
+	if len(sites) != 1 {
+		t.Fatalf("expected 1 call site, got %v", sites)
+	}
+	if sites[0].Arguments != 2 {
+		t.Errorf("expected f(a+b, x[i]) to have 2 arguments, got %d", sites[0].Arguments)
+	}
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SnippetOptions controls which editors we write snippets for and how many of them
+type SnippetOptions struct {
+	// Editor is vscode, ultisnips, yasnippet or all
+	Editor string
+	// Packages is the list of packages to write a snippet set for, one per line
+	Packages string
+	// Out is the directory the snippets are written to, with a directory per editor
+	Out string
+	// Top is the number of templates of every function that are turned into snippets
+	Top int
+}
+
+// Snippet is a call template with tab stops in place of its placeholders
+type Snippet struct {
+	Name        string
+	Prefix      string
+	Description string
+	// Body has ${1:name} tab stops, it's the syntax of all the editors we write for
+	Body string
+}
+
+// snippetsMode reads the output of patterns ("-" is stdin) and writes a snippet file for
+// every package in the list, for every editor asked for
+func snippetsMode(input string, options SnippetOptions) {
+	packages, err := readPackageList(options.Packages)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	reader := io.Reader(os.Stdin)
+	if input != "-" {
+		file, err := os.Open(input)
+		if err != nil {
+			log.Fatalf("%q", err)
+		}
+		defer file.Close()
+		reader = file
+	}
+	patterns, err := readPatterns(reader)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+
+	snippets := make(map[string][]Snippet)
+	perFunction := make(map[string]int)
+	for _, pattern := range patterns {
+		pkg, ok := packageOfReference(packages, pattern.Reference)
+		if !ok {
+			continue
+		}
+		function := pattern.Reference + "#" + pattern.Name
+		if perFunction[function] >= options.Top {
+			continue
+		}
+		perFunction[function]++
+		snippets[pkg] = append(snippets[pkg], toSnippet(pattern, perFunction[function]))
+	}
+
+	writers := map[string]func(string, string, []Snippet) error{
+		"vscode":    writeVSCodeSnippets,
+		"ultisnips": writeUltiSnips,
+		"yasnippet": writeYasnippets,
+	}
+	editors := []string{options.Editor}
+	if options.Editor == "all" {
+		editors = []string{"vscode", "ultisnips", "yasnippet"}
+	}
+	for _, editor := range editors {
+		write, present := writers[editor]
+		if !present {
+			log.Fatalf("unknown editor %q, expected one of vscode, ultisnips, yasnippet or all", editor)
+		}
+		directory := filepath.Join(options.Out, editor)
+		for _, pkg := range packages {
+			if len(snippets[pkg]) == 0 {
+				continue
+			}
+			if err := write(directory, pkg, snippets[pkg]); err != nil {
+				log.Fatalf("%q", err)
+			}
+		}
+	}
+}
+
+// readPatterns reads the reference, name, template, count and examples columns written by patterns
+func readPatterns(reader io.Reader) ([]Pattern, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.Comma = '\t'
+	csvReader.LazyQuotes = true
+	csvReader.FieldsPerRecord = -1
+	patterns := make([]Pattern, 0)
+	for {
+		fields, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 4 {
+			continue
+		}
+		count, _ := strconv.Atoi(fields[3])
+		pattern := Pattern{Reference: fields[0], Name: fields[1], Template: fields[2], Count: count}
+		for _, example := range fields[4:] {
+			if example != "" {
+				pattern.Examples = append(pattern.Examples, example)
+			}
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// packageOfReference returns the package of the list the reference belongs to, the longest
+// one wins so gopkg.in/yaml.v2 or a nested package isn't mistaken for a type
+func packageOfReference(packages []string, reference string) (string, bool) {
+	reference = strings.TrimLeft(reference, "*")
+	found := ""
+	for _, pkg := range packages {
+		if (reference == pkg || strings.HasPrefix(reference, pkg+".")) && len(pkg) > len(found) {
+			found = pkg
+		}
+	}
+	return found, found != ""
+}
+
+// toSnippet turns the placeholders of the template into numbered tab stops, named after
+// their type and made unique by their position
+func toSnippet(pattern Pattern, rank int) Snippet {
+	var body strings.Builder
+	used := make(map[string]int)
+	stop := 0
+	template := pattern.Template
+	for {
+		start := strings.Index(template, "${")
+		if start < 0 {
+			break
+		}
+		end := placeholderEnd(template, start+2)
+		if end < 0 {
+			break
+		}
+		body.WriteString(escapeSnippet(template[:start]))
+		stop++
+		name := placeholderName(template[start+2 : end])
+		used[name]++
+		if used[name] > 1 {
+			name += strconv.Itoa(used[name])
+		}
+		fmt.Fprintf(&body, "${%d:%s}", stop, name)
+		template = template[end+1:]
+	}
+	body.WriteString(escapeSnippet(template))
+
+	// the body of function literals is for the user to write
+	text := strings.Replace(body.String(), " {...}", " {\n\t$0\n}", 1)
+
+	name := shortReference(pattern.Reference) + "." + pattern.Name
+	if rank > 1 {
+		name += " " + strconv.Itoa(rank)
+	}
+	description := fmt.Sprintf("%d uses", pattern.Count)
+	if len(pattern.Examples) > 0 {
+		description += ", e.g. " + strings.Split(pattern.Examples[0], "\n")[0]
+	}
+	return Snippet{Name: name, Prefix: pattern.Name, Description: description, Body: text}
+}
+
+// placeholderEnd returns the index of the } closing the placeholder, types like interface{} have braces of their own
+func placeholderEnd(template string, from int) int {
+	depth := 0
+	for idx := from; idx < len(template); idx++ {
+		switch template[idx] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return idx
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// placeholderNames are the names we give the values of the common types
+var placeholderNames = map[string]string{
+	"string":  "s",
+	"int":     "n",
+	"int64":   "n",
+	"int32":   "n",
+	"uint":    "n",
+	"uint64":  "n",
+	"float64": "f",
+	"float32": "f",
+	"bool":    "ok",
+	"error":   "err",
+	"rune":    "r",
+	"byte":    "b",
+	"[]byte":  "data",
+	"imag":    "c",
+	"float":   "f",
+	"_":       "arg",
+}
+
+// placeholderName derives the name of the tab stop from the type, *gin.Context is context
+func placeholderName(typ string) string {
+	if name, present := placeholderNames[typ]; present {
+		return name
+	}
+	switch {
+	case strings.HasPrefix(typ, "func("):
+		return "fn"
+	case strings.HasPrefix(typ, "map["):
+		return "m"
+	case strings.HasPrefix(typ, "chan ") || strings.HasPrefix(typ, "<-chan ") || strings.HasPrefix(typ, "chan<- "):
+		return "ch"
+	case strings.HasPrefix(typ, "interface{") || typ == "any":
+		return "v"
+	}
+	plural := strings.HasPrefix(typ, "[]")
+	name := strings.TrimLeft(typ, "*[]")
+	if idx := strings.Index(name, "["); idx >= 0 {
+		name = name[:idx]
+	}
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		name = name[idx+1:]
+	}
+	if short, present := placeholderNames[name]; present {
+		name = short
+	}
+	if name == "" {
+		return "arg"
+	}
+	runes := []rune(name)
+	runes[0] = unicode.ToLower(runes[0])
+	name = string(runes)
+	if plural {
+		name += "s"
+	}
+	return name
+}
+
+// escapeSnippet escapes the characters the snippet syntax gives a meaning to
+func escapeSnippet(text string) string {
+	return strings.NewReplacer(`\`, `\\`, `$`, `\$`, "`", "\\`").Replace(text)
+}
+
+// shortReference is the reference with package names instead of import paths, *github.com/gin-gonic/gin.Context is gin.Context
+func shortReference(reference string) string {
+	reference = strings.TrimLeft(reference, "*")
+	if idx := strings.LastIndex(reference, "/"); idx >= 0 {
+		reference = reference[idx+1:]
+	}
+	return reference
+}
+
+// snippetFileName is the package path without the host, github.com/gin-gonic/gin is gin-gonic-gin
+func snippetFileName(pkg string) string {
+	parts := strings.Split(pkg, "/")
+	if len(parts) > 1 && strings.Contains(parts[0], ".") {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, "-")
+}
+
+// writeVSCodeSnippets writes a .code-snippets file, it can be dropped into .vscode/ of a workspace
+func writeVSCodeSnippets(directory string, pkg string, snippets []Snippet) error {
+	type vscodeSnippet struct {
+		Scope       string   `json:"scope"`
+		Prefix      string   `json:"prefix"`
+		Body        []string `json:"body"`
+		Description string   `json:"description"`
+	}
+	entries := make(map[string]vscodeSnippet)
+	for _, snippet := range snippets {
+		entries[snippet.Name] = vscodeSnippet{
+			Scope:       "go",
+			Prefix:      snippet.Prefix,
+			Body:        strings.Split(snippet.Body, "\n"),
+			Description: snippet.Description,
+		}
+	}
+	out, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeSnippetFile(filepath.Join(directory, snippetFileName(pkg)+".code-snippets"), out)
+}
+
+// writeUltiSnips writes a go_<package>.snippets file, UltiSnips picks up all the go_*.snippets files
+func writeUltiSnips(directory string, pkg string, snippets []Snippet) error {
+	var out strings.Builder
+	fmt.Fprintf(&out, "# %s\n", pkg)
+	for _, snippet := range snippets {
+		fmt.Fprintf(&out, "\nsnippet %s \"%s\"\n%s\nendsnippet\n", snippet.Prefix, strings.Replace(snippet.Description, `"`, `'`, -1), snippet.Body)
+	}
+	return writeSnippetFile(filepath.Join(directory, "go_"+snippetFileName(pkg)+".snippets"), []byte(out.String()))
+}
+
+// writeYasnippets writes a file per snippet under go-mode/<package>, the directory is the group of the snippets
+func writeYasnippets(directory string, pkg string, snippets []Snippet) error {
+	for idx, snippet := range snippets {
+		var out strings.Builder
+		out.WriteString("# -*- mode: snippet -*-\n")
+		fmt.Fprintf(&out, "# name: %s\n", snippet.Name)
+		fmt.Fprintf(&out, "# key: %s\n", snippet.Prefix)
+		fmt.Fprintf(&out, "# group: %s\n", pkg)
+		out.WriteString("# --\n")
+		out.WriteString(snippet.Body)
+		filename := fmt.Sprintf("%s-%d", snippet.Prefix, idx+1)
+		if err := writeSnippetFile(filepath.Join(directory, "go-mode", snippetFileName(pkg), filename), []byte(out.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeSnippetFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, content, 0644)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Target is a platform the build constraints are evaluated for
+type Target struct {
+	GOOS   string
+	GOARCH string
+}
+
+func (t Target) String() string {
+	return t.GOOS + "/" + t.GOARCH
+}
+
+// parseTargets reads the -targets flag: host for the platform we run on, all for every
+// platform the go tool supports, any to parse every file irrespective of its constraints, or a
+// comma separated list like linux/amd64,darwin/arm64
+func parseTargets(value string) ([]Target, error) {
+	switch value {
+	case "any":
+		return nil, nil
+	case "", "host":
+		return []Target{{GOOS: runtime.GOOS, GOARCH: runtime.GOARCH}}, nil
+	case "all":
+		return allTargets()
+	}
+	targets := make([]Target, 0)
+	for _, target := range strings.Split(value, ",") {
+		parts := strings.Split(strings.TrimSpace(target), "/")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid target %q, expected GOOS/GOARCH", target)
+		}
+		targets = append(targets, Target{GOOS: parts[0], GOARCH: parts[1]})
+	}
+	return targets, nil
+}
+
+// allTargets returns every GOOS/GOARCH combination the go tool can build for
+func allTargets() ([]Target, error) {
+	cmd := exec.Command("go", "tool", "dist", "list")
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("go tool dist list failed: %v", err)
+	}
+	return parseTargets(strings.Join(strings.Fields(out.String()), ","))
+}
+
+// selectFiles drops the files that aren't built for any of the targets, and cgo files when
+// SkipCgo is set. It returns the targets each of the remaining files is built for, which is
+// nil when every file is parsed irrespective of its constraints.
+func selectFiles(directory string, filenames []string, options ParseOptions) ([]string, map[string][]string) {
+	var fileTargets map[string][]string
+	if len(options.Targets) > 0 {
+		fileTargets = make(map[string][]string)
+	}
+	selected := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		if options.SkipCgo && importsC(filepath.Join(directory, filename)) {
+			continue
+		}
+		if nil == fileTargets {
+			selected = append(selected, filename)
+			continue
+		}
+		for _, target := range options.Targets {
+			context := build.Default
+			context.GOOS = target.GOOS
+			context.GOARCH = target.GOARCH
+			context.CgoEnabled = !options.SkipCgo
+			// a file we can't read is kept, parsePackage reports the error
+			if matched, err := context.MatchFile(directory, filename); matched || err != nil {
+				fileTargets[filename] = append(fileTargets[filename], target.String())
+			}
+		}
+		if len(fileTargets[filename]) > 0 {
+			selected = append(selected, filename)
+		}
+	}
+	return selected, fileTargets
+}
+
+// importsC tells if the file uses cgo
+func importsC(path string) bool {
+	fileAst, err := parser.ParseFile(token.NewFileSet(), path, nil, parser.ImportsOnly)
+	if nil == fileAst || err != nil {
+		return false
+	}
+	for _, spec := range fileAst.Imports {
+		if importPath, _ := strconv.Unquote(spec.Path.Value); importPath == "C" {
+			return true
+		}
+	}
+	return false
+}
+
+// buildConstraint returns the //go:build expression of the file, or the // +build lines of
+// older files combined into one
+func buildConstraint(fileAst *ast.File) string {
+	var plusBuild constraint.Expr
+	for _, group := range fileAst.Comments {
+		if group.Pos() >= fileAst.Package {
+			break
+		}
+		for _, comment := range group.List {
+			if constraint.IsGoBuild(comment.Text) {
+				if expr, err := constraint.Parse(comment.Text); err == nil {
+					return expr.String()
+				}
+			}
+			if constraint.IsPlusBuild(comment.Text) {
+				if expr, err := constraint.Parse(comment.Text); err == nil {
+					if nil == plusBuild {
+						plusBuild = expr
+					} else {
+						plusBuild = &constraint.AndExpr{X: plusBuild, Y: expr}
+					}
+				}
+			}
+		}
+	}
+	if nil == plusBuild {
+		return ""
+	}
+	return plusBuild.String()
+}
+
+// typeCheckTargets type checks the files built together for each of the targets, so the
+// variants of a declaration for different platforms don't clash. A file is walked with the
+// types of the first target it's built for. Without targets all the files are checked together.
+func typeCheckTargets(fset *token.FileSet, packagePath string, packageName string, filenames []string, fileAsts []*ast.File, fileTargets map[string][]string) []*types.Info {
+	targetsOf := func(idx int) []string {
+		if nil == fileTargets {
+			return []string{""}
+		}
+		return fileTargets[filenames[idx]]
+	}
+	groups := make(map[string][]int)
+	for idx, fileAst := range fileAsts {
+		if nil == fileAst {
+			continue
+		}
+		for _, target := range targetsOf(idx) {
+			groups[target] = append(groups[target], idx)
+		}
+	}
+
+	// most platforms build the same files, they're checked once
+	checked := make(map[string]map[string]*types.Info)
+	infos := make([]*types.Info, len(fileAsts))
+	for idx, fileAst := range fileAsts {
+		if nil == fileAst || len(targetsOf(idx)) == 0 {
+			continue
+		}
+		indices := groups[targetsOf(idx)[0]]
+		key := fmt.Sprint(indices)
+		byName, seen := checked[key]
+		if !seen {
+			groupAsts := make([]*ast.File, 0, len(indices))
+			for _, member := range indices {
+				groupAsts = append(groupAsts, fileAsts[member])
+			}
+			// external tests in package foo_test are a package of their own, so they're type checked separately
+			// and import the package under test as it's checked here
+			byName = make(map[string]*types.Info)
+			packages := filesByPackage(groupAsts)
+			checkedPackages := make(map[string]*types.Package)
+			if parsedAsts, present := packages[packageName]; present {
+				info, pkg := typeCheck(fset, packagePath, parsedAsts, nil)
+				byName[packageName] = info
+				if nil != pkg {
+					checkedPackages[packagePath] = pkg
+				}
+			}
+			for name, parsedAsts := range packages {
+				if name == packageName {
+					continue
+				}
+				path := packagePath
+				if isExternalTest(name, packageName) {
+					path = packagePath + "_test"
+				}
+				byName[name], _ = typeCheck(fset, path, parsedAsts, checkedPackages)
+			}
+			checked[key] = byName
+		}
+		infos[idx] = byName[fileAst.Name.String()]
+	}
+	return infos
+}
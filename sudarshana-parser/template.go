@@ -0,0 +1,238 @@
+package main
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// literalPlaceholders abstract the literals by their kind
+var literalPlaceholders = map[token.Token]string{
+	token.STRING: "${string}",
+	token.INT:    "${int}",
+	token.FLOAT:  "${float}",
+	token.CHAR:   "${rune}",
+	token.IMAG:   "${imag}",
+}
+
+// untypedPlaceholder stands in for identifiers the type checker couldn't resolve
+const untypedPlaceholder = "${_}"
+
+// templateOf renders the expression with the local identifiers replaced by a placeholder of their
+// type and the literals by a placeholder of their kind, so Logger.Printf("a %s", x) and
+// Logger.Printf("b %s", y) are both Logger.Printf(${string}, ${string}). Package names,
+// functions, types, fields and methods are kept as they're the pattern itself.
+func templateOf(expr ast.Expr, fset *token.FileSet, info *types.Info) string {
+	if nil == expr {
+		return ""
+	}
+	if placeholder, ok := constantPlaceholder(expr, info); ok {
+		return placeholder
+	}
+	switch e := expr.(type) {
+	case *ast.Ident:
+		return identTemplate(e, info)
+	case *ast.BasicLit:
+		return literalPlaceholders[e.Kind]
+	case *ast.CallExpr:
+		args := make([]string, 0, len(e.Args))
+		for _, arg := range e.Args {
+			args = append(args, templateOf(arg, fset, info))
+		}
+		ellipsis := ""
+		if e.Ellipsis.IsValid() {
+			ellipsis = "..."
+		}
+		return calleeTemplate(e.Fun, fset, info) + "(" + strings.Join(args, ", ") + ellipsis + ")"
+	case *ast.SelectorExpr:
+		if ident, ok := e.X.(*ast.Ident); ok && nil == objectOf(info, ident) {
+			// most likely a package we couldn't import
+			return ident.Name + "." + e.Sel.Name
+		}
+		return templateOf(e.X, fset, info) + "." + e.Sel.Name
+	case *ast.StarExpr:
+		return "*" + templateOf(e.X, fset, info)
+	case *ast.UnaryExpr:
+		return e.Op.String() + templateOf(e.X, fset, info)
+	case *ast.BinaryExpr:
+		return templateOf(e.X, fset, info) + " " + e.Op.String() + " " + templateOf(e.Y, fset, info)
+	case *ast.ParenExpr:
+		return "(" + templateOf(e.X, fset, info) + ")"
+	case *ast.IndexExpr:
+		return templateOf(e.X, fset, info) + "[" + templateOf(e.Index, fset, info) + "]"
+	case *ast.SliceExpr:
+		slice := templateOf(e.X, fset, info) + "[" + templateOf(e.Low, fset, info) + ":" + templateOf(e.High, fset, info)
+		if e.Slice3 {
+			slice += ":" + templateOf(e.Max, fset, info)
+		}
+		return slice + "]"
+	case *ast.KeyValueExpr:
+		return templateOf(e.Key, fset, info) + ": " + templateOf(e.Value, fset, info)
+	case *ast.TypeAssertExpr:
+		if nil == e.Type {
+			return templateOf(e.X, fset, info) + ".(type)"
+		}
+		return templateOf(e.X, fset, info) + ".(" + nodeText(fset, e.Type) + ")"
+	case *ast.CompositeLit:
+		elements := make([]string, 0, len(e.Elts))
+		for _, element := range e.Elts {
+			if keyValue, ok := element.(*ast.KeyValueExpr); ok {
+				// the keys of struct literals are field names, they're part of the pattern
+				if key, ok := keyValue.Key.(*ast.Ident); ok && isStructLit(e, info) {
+					elements = append(elements, key.Name+": "+templateOf(keyValue.Value, fset, info))
+					continue
+				}
+			}
+			elements = append(elements, templateOf(element, fset, info))
+		}
+		typ := ""
+		if nil != e.Type {
+			typ = nodeText(fset, e.Type)
+		}
+		return typ + "{" + strings.Join(elements, ", ") + "}"
+	case *ast.FuncLit:
+		// the body is the caller's code, only the signature is part of the pattern
+		return nodeText(fset, e.Type) + " {...}"
+	}
+	return nodeText(fset, expr)
+}
+
+// calleeTemplate keeps the name of the function called, f(x) is f(${int}) and not ${func(int)}(${int}).
+// Explicit type arguments are left out, so Map[int, string](xs, f) is the same pattern as Map(xs, f),
+// the TypeArgs of the call have them.
+func calleeTemplate(fun ast.Expr, fset *token.FileSet, info *types.Info) string {
+	switch f := fun.(type) {
+	case *ast.Ident:
+		if obj := objectOf(info, f); nil == obj || !isValue(obj) {
+			return f.Name
+		}
+	case *ast.IndexExpr:
+		if typeAndValue, ok := typeAndValueOf(info, f.Index); ok && typeAndValue.IsType() {
+			return calleeTemplate(f.X, fset, info)
+		}
+	case *ast.IndexListExpr:
+		return calleeTemplate(f.X, fset, info)
+	case *ast.ParenExpr, *ast.ArrayType, *ast.MapType, *ast.ChanType, *ast.FuncType, *ast.InterfaceType:
+		// conversions like []byte(s) or (*T)(p)
+		if typeAndValue, ok := typeAndValueOf(info, fun); ok && typeAndValue.IsType() {
+			return nodeText(fset, fun)
+		}
+	}
+	return templateOf(fun, fset, info)
+}
+
+// identTemplate replaces variables and the constants of the package with a placeholder of their type
+func identTemplate(ident *ast.Ident, info *types.Info) string {
+	if nil == info {
+		switch ident.Name {
+		case "nil", "true", "false", "iota":
+			return ident.Name
+		}
+		return untypedPlaceholder
+	}
+	obj := objectOf(info, ident)
+	if nil == obj {
+		return untypedPlaceholder
+	}
+	switch o := obj.(type) {
+	case *types.PkgName:
+		// import aliases are local too, we write the package by its own name
+		return o.Imported().Name()
+	case *types.Var, *types.Const:
+		if nil == obj.Pkg() || !isValue(obj) {
+			return ident.Name
+		}
+		return typePlaceholder(obj.Type())
+	}
+	return ident.Name
+}
+
+// constantPlaceholder abstracts constant expressions built only from literals, like "a" + "b"
+// or 1 << 10, to the placeholder of their kind
+func constantPlaceholder(expr ast.Expr, info *types.Info) (string, bool) {
+	switch expr.(type) {
+	case *ast.BinaryExpr, *ast.ParenExpr, *ast.UnaryExpr:
+	default:
+		return "", false
+	}
+	typeAndValue, ok := typeAndValueOf(info, expr)
+	if !ok || nil == typeAndValue.Value || !onlyLiterals(expr) {
+		return "", false
+	}
+	if basic, ok := typeAndValue.Type.Underlying().(*types.Basic); ok {
+		switch {
+		case basic.Info()&types.IsString != 0:
+			return literalPlaceholders[token.STRING], true
+		case basic.Info()&types.IsInteger != 0:
+			return literalPlaceholders[token.INT], true
+		case basic.Info()&types.IsFloat != 0:
+			return literalPlaceholders[token.FLOAT], true
+		case basic.Info()&types.IsComplex != 0:
+			return literalPlaceholders[token.IMAG], true
+		}
+	}
+	return "", false
+}
+
+func onlyLiterals(expr ast.Expr) bool {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.BinaryExpr:
+		return onlyLiterals(e.X) && onlyLiterals(e.Y)
+	case *ast.ParenExpr:
+		return onlyLiterals(e.X)
+	case *ast.UnaryExpr:
+		return onlyLiterals(e.X)
+	}
+	return false
+}
+
+// typePlaceholder writes the type with the package names instead of import paths, to keep the templates short
+func typePlaceholder(typ types.Type) string {
+	if nil == typ {
+		return untypedPlaceholder
+	}
+	if basic, ok := typ.(*types.Basic); ok && basic.Kind() == types.Invalid {
+		return untypedPlaceholder
+	}
+	return "${" + types.TypeString(types.Default(typ), func(pkg *types.Package) string {
+		return pkg.Name()
+	}) + "}"
+}
+
+func objectOf(info *types.Info, ident *ast.Ident) types.Object {
+	if nil == info {
+		return nil
+	}
+	return info.ObjectOf(ident)
+}
+
+func typeAndValueOf(info *types.Info, expr ast.Expr) (types.TypeAndValue, bool) {
+	if nil == info {
+		return types.TypeAndValue{}, false
+	}
+	typeAndValue, ok := info.Types[expr]
+	return typeAndValue, ok
+}
+
+// isValue tells variables (but not struct fields) and constants apart from functions, types and packages
+func isValue(obj types.Object) bool {
+	switch o := obj.(type) {
+	case *types.Var:
+		return !o.IsField()
+	case *types.Const:
+		return true
+	}
+	return false
+}
+
+func isStructLit(lit *ast.CompositeLit, info *types.Info) bool {
+	if typeAndValue, ok := typeAndValueOf(info, lit); ok && nil != typeAndValue.Type && typeAndValue.Type != types.Typ[types.Invalid] {
+		_, isStruct := typeAndValue.Type.Underlying().(*types.Struct)
+		return isStruct
+	}
+	// without the type of the literal keyed elements are most likely fields, when it has a type
+	return nil != lit.Type
+}
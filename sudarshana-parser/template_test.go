@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestTemplateOfGenericCalls(t *testing.T) {
+	const src = `package generic
+
+func Map[T, U any](xs []T, f func(T) U) []U { return nil }
+
+func run(xs []int) {
+	Map[int, string](xs, nil)
+	Map(xs, func(int) string { return "" })
+}
+`
+	directory := writeFiles(t, map[string]string{"a.go": src})
+	sources := parsePackage("generic", "generic", directory, nil, Meta{}, []string{"a.go"}, ParseOptions{})
+
+	templates := make([]string, 0)
+	for _, source := range sources {
+		walkCalls(source.Exprs, func(call Func) {
+			if call.Name == "Map" {
+				templates = append(templates, call.Template)
+			}
+		})
+	}
+	expected := []string{"Map(${[]int}, nil)", "Map(${[]int}, func(int) string {...})"}
+	if len(templates) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, templates)
+	}
+	for idx := range expected {
+		if templates[idx] != expected[idx] {
+			t.Errorf("expected %q, got %q", expected[idx], templates[idx])
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// https://golang.org/s/generatedcode
+var generatedCodeRegex = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// goFiles returns the go files in the directory sorted by name, test files are only included on request
+func goFiles(directory string, options ParseOptions) ([]string, error) {
+	files, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, err
+	}
+	filenames := make([]string, 0)
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), ".go") && (options.IncludeTests || !strings.HasSuffix(file.Name(), "_test.go")) {
+			if options.SkipGenerated && isGenerated(filepath.Join(directory, file.Name())) {
+				continue
+			}
+			filenames = append(filenames, file.Name())
+		}
+	}
+	return filenames, nil
+}
+
+// isGenerated checks for the "Code generated ... DO NOT EDIT." comment before the package clause
+func isGenerated(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if generatedCodeRegex.MatchString(line) {
+			return true
+		}
+		if strings.HasPrefix(line, "package ") {
+			return false
+		}
+	}
+	return false
+}
+
+// readModulePath returns the module path declared in the go.mod file
+func readModulePath(gomod string) (string, bool) {
+	modulePath, _, ok := readGoMod(gomod)
+	return modulePath, ok && modulePath != ""
+}
+
+// readGoMod returns the module path and the go version declared in the go.mod file
+func readGoMod(gomod string) (string, string, bool) {
+	file, err := os.Open(gomod)
+	if err != nil {
+		return "", "", false
+	}
+	defer file.Close()
+
+	modulePath, goVersion := "", ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "module":
+			modulePath = strings.Trim(fields[1], `"`)
+		case "go":
+			goVersion = fields[1]
+		}
+	}
+	return modulePath, goVersion, true
+}
+
+// moduleOf finds the nearest go.mod at or above directory (but not above root) and
+// returns the import path of the directory along with the module it belongs to and the
+// directory of its go.mod, root for the directories outside of a module.
+func moduleOf(root string, directory string, modules map[string]string) (string, *Module, string) {
+	for dir := directory; ; dir = filepath.Dir(dir) {
+		modulePath, seen := modules[dir]
+		if !seen {
+			modulePath, _ = readModulePath(filepath.Join(dir, "go.mod"))
+			modules[dir] = modulePath
+		}
+		if modulePath != "" {
+			rel, _ := filepath.Rel(dir, directory)
+			importPath := modulePath
+			if rel != "." {
+				importPath = modulePath + "/" + filepath.ToSlash(rel)
+			}
+			return importPath, &Module{Path: modulePath}, dir
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			break
+		}
+	}
+	rel, _ := filepath.Rel(root, directory)
+	if rel == "." {
+		// parsePackage falls back to the package name
+		return "", nil, root
+	}
+	return filepath.ToSlash(rel), nil, root
+}
+
+// parsetree walks the repository (or module) root and parses every package found in it.
+// A SourceFile record is streamed for each file as soon as its package is parsed.
+func parsetree(root string, options ParseOptions, sink Sink) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+	modules := make(map[string]string)
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			log.Printf("failed to walk %s: %v", path, err)
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		name := info.Name()
+		if path != root {
+			// the go tool ignores these directories as well
+			if name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") {
+				return filepath.SkipDir
+			}
+			if options.SkipVendor && name == "vendor" {
+				return filepath.SkipDir
+			}
+		}
+
+		filenames, err := goFiles(path, options)
+		if err != nil {
+			log.Printf("failed to list %s: %v", path, err)
+			return nil
+		}
+		if len(filenames) > 0 {
+			importPath, module, moduleDir := moduleOf(root, path, modules)
+			meta := options.Metadata.metaOf(path, module, moduleDir)
+			for _, source := range cachedParsePackage(stripVendorPath(importPath), "", path, module, meta, filenames, options) {
+				writeSource(sink, source)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Fatalf("%q", err)
+	}
+}
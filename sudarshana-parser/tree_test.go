@@ -0,0 +1,28 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModuleOfNestedModules(t *testing.T) {
+	root := writeFiles(t, map[string]string{"go.mod": "module example.com/repo\n"})
+	nested := filepath.Join(root, "tools", "gen")
+	if err := os.MkdirAll(filepath.Join(nested, "cmd"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(nested, "go.mod"), []byte("module example.com/repo/tools/gen\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	modules := make(map[string]string)
+	importPath, module, moduleDir := moduleOf(root, filepath.Join(nested, "cmd"), modules)
+	if importPath != "example.com/repo/tools/gen/cmd" || module.Path != "example.com/repo/tools/gen" || moduleDir != nested {
+		t.Errorf("expected the nested module, got %s %v %s", importPath, module, moduleDir)
+	}
+	if _, _, moduleDir := moduleOf(root, filepath.Join(root, "tools"), modules); moduleDir != root {
+		t.Errorf("expected the root module for tools, got %s", moduleDir)
+	}
+}
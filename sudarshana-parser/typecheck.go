@@ -0,0 +1,177 @@
+package main
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/token"
+	"go/types"
+	"strings"
+)
+
+// typeCheck runs the go/types checker over all the files of a package so
+// selector expressions can be resolved to their fully-qualified package path
+// and receiver type. Type errors are ignored, a partially checked package
+// still resolves most of the calls. Imports of the packages already checked,
+// the package under test of an external test, are answered with them.
+func typeCheck(fset *token.FileSet, packagePath string, files []*ast.File, checked map[string]*types.Package) (*types.Info, *types.Package) {
+	info := &types.Info{
+		Types:      make(map[ast.Expr]types.TypeAndValue),
+		Defs:       make(map[*ast.Ident]types.Object),
+		Uses:       make(map[*ast.Ident]types.Object),
+		Selections: make(map[*ast.SelectorExpr]*types.Selection),
+		Instances:  make(map[*ast.Ident]types.Instance),
+	}
+	conf := types.Config{
+		Importer:    checkedImporter{ImporterFrom: importer.ForCompiler(fset, "source", nil).(types.ImporterFrom), checked: checked},
+		FakeImportC: true,
+		Error:       func(err error) {},
+	}
+	pkg, _ := conf.Check(packagePath, fset, files, info)
+	return info, pkg
+}
+
+// checkedImporter imports the packages type checked from the files we parsed before falling
+// back to the sources of the build
+type checkedImporter struct {
+	types.ImporterFrom
+	checked map[string]*types.Package
+}
+
+func (i checkedImporter) Import(path string) (*types.Package, error) {
+	return i.ImportFrom(path, "", 0)
+}
+
+func (i checkedImporter) ImportFrom(path string, dir string, mode types.ImportMode) (*types.Package, error) {
+	if pkg, present := i.checked[path]; present {
+		return pkg, nil
+	}
+	return i.ImporterFrom.ImportFrom(path, dir, mode)
+}
+
+// qualifier writes every package as its full import path
+func qualifier(pkg *types.Package) string {
+	return stripVendorPath(pkg.Path())
+}
+
+// typeName returns the fully-qualified name of the type, e.g. *github.com/gin-gonic/gin.Context
+func typeName(typ types.Type) string {
+	return types.TypeString(typ, qualifier)
+}
+
+// genericTypeName returns the name of the type without its type arguments, so all the
+// instantiations of a generic type are the same type - *pkg.List[int] becomes *pkg.List, and so
+// does the generic type itself
+func genericTypeName(typ types.Type) string {
+	prefix := ""
+	if pointer, ok := typ.(*types.Pointer); ok {
+		prefix = "*"
+		typ = pointer.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || (named.TypeArgs().Len() == 0 && named.TypeParams().Len() == 0) {
+		return prefix + typeName(typ)
+	}
+	obj := named.Obj()
+	if nil == obj.Pkg() {
+		return prefix + obj.Name()
+	}
+	return prefix + qualifier(obj.Pkg()) + "." + obj.Name()
+}
+
+// typeArgsOf returns the type arguments of an instantiated generic type
+func typeArgsOf(typ types.Type) []string {
+	if pointer, ok := typ.(*types.Pointer); ok {
+		typ = pointer.Elem()
+	}
+	named, ok := typ.(*types.Named)
+	if !ok || nil == named.TypeArgs() {
+		return nil
+	}
+	typeArgs := make([]string, 0, named.TypeArgs().Len())
+	for idx := 0; idx < named.TypeArgs().Len(); idx++ {
+		typeArgs = append(typeArgs, typeName(named.TypeArgs().At(idx)))
+	}
+	return typeArgs
+}
+
+// resolveSelector returns the fully-qualified receiver of the selector expression. It's
+// the receiver type for method calls and the import path for package level functions.
+func resolveSelector(info *types.Info, sel *ast.SelectorExpr) (string, bool) {
+	if nil == info {
+		return "", false
+	}
+	if selection, ok := info.Selections[sel]; ok {
+		return genericTypeName(selection.Recv()), true
+	}
+	if ident, ok := sel.X.(*ast.Ident); ok {
+		if pkgName, ok := info.Uses[ident].(*types.PkgName); ok {
+			return stripVendorPath(pkgName.Imported().Path()), true
+		}
+	}
+	return "", false
+}
+
+// resolveCallee returns the fully-qualified name of the function a selector calls. Methods
+// are named after the type that declares them, sync.Mutex#Lock for s.Lock() on a struct
+// embedding a sync.Mutex, and fields holding a func have no callee.
+func resolveCallee(info *types.Info, sel *ast.SelectorExpr) (string, bool) {
+	if nil == info {
+		return "", false
+	}
+	if selection, ok := info.Selections[sel]; ok {
+		fn, ok := selection.Obj().(*types.Func)
+		if !ok {
+			return "", false
+		}
+		if recv := fn.Type().(*types.Signature).Recv(); nil != recv {
+			return genericTypeName(recv.Type()) + "#" + fn.Name(), true
+		}
+		return fn.FullName(), true
+	}
+	if resolved, ok := resolveSelector(info, sel); ok {
+		return resolved + "#" + sel.Sel.Name, true
+	}
+	return "", false
+}
+
+// resolveFunc returns the fully-qualified name of a function called by its name, like
+// github.com/gin-gonic/gin#New for New() within gin
+func resolveFunc(info *types.Info, ident *ast.Ident) (string, bool) {
+	if nil == info || nil == ident {
+		return "", false
+	}
+	fn, ok := info.Uses[ident].(*types.Func)
+	if !ok || nil == fn.Pkg() {
+		return "", false
+	}
+	return qualifier(fn.Pkg()) + "#" + fn.Name(), true
+}
+
+// resolveType returns the fully-qualified type of the expression if the type checker knows about it
+func resolveType(info *types.Info, expr ast.Expr) (string, bool) {
+	if nil == info {
+		return "", false
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		if obj := info.ObjectOf(ident); obj != nil && obj.Type() != nil && obj.Type() != types.Typ[types.UntypedNil] {
+			return typeName(obj.Type()), true
+		}
+	}
+	if typ := info.TypeOf(expr); typ != nil {
+		if basic, ok := typ.(*types.Basic); ok && (basic.Kind() == types.Invalid || basic.Kind() == types.UntypedNil) {
+			return "", false
+		}
+		return typeName(typ), true
+	}
+	return "", false
+}
+
+func stripVendorPath(path string) string {
+	if strings.Contains(path, "/vendor/") {
+		// we've a vendored path, filter things before /vendor/ to get the import path
+		parts := strings.Split(path, "/vendor/")
+		return parts[len(parts)-1]
+	}
+
+	return path
+}
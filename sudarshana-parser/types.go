@@ -1,179 +1,68 @@
 package main
 
 import (
-	"go/token"
+	"github.com/ashwanthkumar/devmerge_2k18/sudarshana-parser/schema"
 )
 
-func GetAllPositions(exprs []Expr) []token.Pos {
-	positions := make([]token.Pos, 0)
-	for _, expr := range exprs {
-		// fmt.Printf("%v\n", expr)
-		positions = append(positions, expr.AllPos()...)
-	}
-	return positions
-}
-
-// Meta represents the metadata for the SourceFile
-type Meta struct {
-	Source string `json:"source"`
-}
-
-// SourceFile represents the parsed AST for the given file
-type SourceFile struct {
-	Meta    Meta   `json:"meta"`
-	Path    string `json:"path"`
-	Package string `json:"package"`
-	File    string `json:"file"`
-	Exprs   []Expr `json:"lines"`
-}
-
-// Base type of all Expressions
-type Expr interface {
-	Scope() string
-	Pos() token.Pos
-	AllPos() []token.Pos
-}
-
-// Func represents a function call
-type Func struct {
-	Name string `json:"name"`
-	// Reference has a non-empty value, if this instance is invoked from a package or a struct
-	Reference string    `json:"reference,omitempty"`
-	Args      []Expr    `json:"arguments"`
-	Offset    token.Pos `json:"offset"`
-	CScope    string    `json:"scope"`
-	Type      string    `json:"type"`
-	Code      string    `json:"code"`
-}
-
-func (v Func) Pos() token.Pos {
-	return v.Offset
-}
-
-func (v Func) Scope() string {
-	return v.CScope
-}
-
-func (v Func) AllPos() []token.Pos {
-	positions := make([]token.Pos, 0)
-	positions = append(positions, v.Pos())
-	positions = append(positions, GetAllPositions(v.Args)...)
-	return positions
-}
-
-// Variable represents a variable access in an expression
-type Variable struct {
-	Name string `json:"name"`
-	// Reference has a non-empty value, if this instance is invoked from a package or a struct
-	Reference string    `json:"reference,omitempty"`
-	Offset    token.Pos `json:"offset"`
-	CScope    string    `json:"scope"`
-	Type      string    `json:"type"`
-	Code      string    `json:"code"`
-}
-
-func (v Variable) Pos() token.Pos {
-	return v.Offset
-}
-
-func (v Variable) Scope() string {
-	return v.CScope
-}
-
-func (v Variable) AllPos() []token.Pos {
-	positions := make([]token.Pos, 0)
-	positions = append(positions, v.Pos())
-	return positions
-}
-
-// Value represents a constanct of type string, int, double etc.
-type Value struct {
-	TypeOf string    `json:"typeOf"`
-	Value  string    `json:"value"`
-	Offset token.Pos `json:"offset"`
-	CScope string    `json:"scope"`
-	Type   string    `json:"type"`
-	Code   string    `json:"code"`
-}
-
-func (v Value) Pos() token.Pos {
-	return v.Offset
-}
-
-func (v Value) Scope() string {
-	return v.CScope
-}
-
-func (v Value) AllPos() []token.Pos {
-	positions := make([]token.Pos, 0)
-	positions = append(positions, v.Pos())
-	return positions
-}
-
-// Assignment represents an assignment expression
-type Assignment struct {
-	Lefts  []Expr    `json:"lhs"`
-	Right  Expr      `json:"rhs"`
-	Offset token.Pos `json:"offset"`
-	CScope string    `json:"scope"`
-	Type   string    `json:"type"`
-	Code   string    `json:"code"`
-}
-
-func (v Assignment) Pos() token.Pos {
-	return v.Offset
-}
-
-func (v Assignment) Scope() string {
-	return v.CScope
-}
+// The records we emit live in the schema package, so the programs consuming
+// the stream decode it with the same types
+type (
+	SourceFile             = schema.SourceFile
+	Meta                   = schema.Meta
+	Module                 = schema.Module
+	Example                = schema.Example
+	ParseError             = schema.ParseError
+	Location               = schema.Location
+	Scope                  = schema.Scope
+	Statement              = schema.Statement
+	Expr                   = schema.Expr
+	Func                   = schema.Func
+	Variable               = schema.Variable
+	Value                  = schema.Value
+	Assignment             = schema.Assignment
+	AssignmentPair         = schema.AssignmentPair
+	PropertyAccessInStruct = schema.PropertyAccessInStruct
+	ConstructStruct        = schema.ConstructStruct
+	FieldValue             = schema.FieldValue
+	Argument               = schema.Argument
+)
 
-func (v Assignment) AllPos() []token.Pos {
-	positions := make([]token.Pos, 0)
-	positions = append(positions, GetAllPositions(v.Lefts)...)
-	if nil != v.Right {
-		positions = append(positions, v.Right.AllPos()...)
+// withStatement returns the expression annotated with the statement it's extracted from, the
+// calls and values nested in its receiver, arguments, sides or fields included
+func withStatement(expr Expr, statement Statement) Expr {
+	switch e := expr.(type) {
+	case Func:
+		e.Statement = statement
+		e.Receiver = withStatement(e.Receiver, statement)
+		withStatements(e.Args, statement)
+		return e
+	case Variable:
+		e.Statement = statement
+		return e
+	case Value:
+		e.Statement = statement
+		return e
+	case Assignment:
+		e.Statement = statement
+		withStatements(e.Lefts, statement)
+		withStatements(e.Rights, statement)
+		return e
+	case PropertyAccessInStruct:
+		e.Statement = statement
+		e.Receiver = withStatement(e.Receiver, statement)
+		return e
+	case ConstructStruct:
+		e.Statement = statement
+		for idx := range e.Fields {
+			e.Fields[idx].Value = withStatement(e.Fields[idx].Value, statement)
+		}
+		return e
 	}
-	return positions
+	return expr
 }
 
-// PropertyAccessInStruct represents accessing a property from a struct
-type PropertyAccessInStruct struct {
-	Struct   string    `json:"struct"`
-	Property string    `json:"property"`
-	Offset   token.Pos `json:"offset"`
-	CScope   string    `json:"scope"`
-	Type     string    `json:"type"`
-	Code     string    `json:"code"`
-}
-
-func (v PropertyAccessInStruct) Pos() token.Pos {
-	return v.Offset
-}
-func (v PropertyAccessInStruct) Scope() string {
-	return v.CScope
-}
-
-type ConstructStruct struct {
-	Struct       string            `json:"struct"`
-	Args         []string          `json:"arguments,omitempty"`
-	KeyValueArgs map[string]string `json:"kvargs,omitempty"`
-	CScope       string            `json:"scope"`
-	Type         string            `json:"type"`
-	Offset       token.Pos         `json:"offset"`
-	Code         string            `json:"code"`
-}
-
-func (v ConstructStruct) Pos() token.Pos {
-	return v.Offset
-}
-
-func (v ConstructStruct) Scope() string {
-	return v.CScope
-}
-
-func (v ConstructStruct) AllPos() []token.Pos {
-	positions := make([]token.Pos, 0)
-	positions = append(positions, v.Pos())
-	return positions
+func withStatements(exprs []Expr, statement Statement) {
+	for idx := range exprs {
+		exprs[idx] = withStatement(exprs[idx], statement)
+	}
 }
@@ -42,6 +42,8 @@ func readAndPopulatePopularPatterns() map[string][]MethodSample {
 	csvReader := csv.NewReader(file)
 	csvReader.Comma = '\t'
 	csvReader.LazyQuotes = true
+	// the rows written by sudarshana patterns have the count and examples as extra columns
+	csvReader.FieldsPerRecord = -1
 	for {
 		fields, err := csvReader.Read()
 		if err == io.EOF {